@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	REFRESH_TOKEN_FILE = `refresh_token`                             // Refresh token file name in state directory
+	DEFAULT_REDIRECT   = `http://127.0.0.1:65010/authorize_callback` // Default redirect URI for authorize command
+	AUTHORIZE_TIMEOUT  = time.Minute * 10                            // How long to wait for user to authorize the app
+)
+
+// OAuth scopes requested in authorize command
+var REDDIT_SCOPES = []string{`identity`, `read`, `submit`, `edit`, `modposts`, `flair`, `mysubreddits`}
+
+// Load refresh token from file
+// Returns empty token if file doesn't exist
+func LoadRefreshToken(fname string) (string, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ``, nil
+		}
+
+		return ``, err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Save refresh token to file readable only by the owner
+func SaveRefreshToken(fname string, token string) error {
+	// Temporary file is created with 0600
+	return writeFileAtomic(fname, []byte(token+"\n"))
+}
+
+// Random state for authorization request
+func newAuthState() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return ``, err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Run OAuth authorization code flow and store refresh token in state directory
+// User opens the printed URL in browser and Reddit redirects back to local listener at redirectUri
+func Authorize(ctx context.Context, cfg Configuration, redirectUri string, debugHTTP bool) error {
+	redirect, err := url.Parse(redirectUri)
+	if err != nil {
+		return fmt.Errorf(`invalid redirect URI %v: %v`, redirectUri, err)
+	}
+
+	if redirect.Scheme != `http` || redirect.Host == `` {
+		return fmt.Errorf(`redirect URI must be a local http:// address, got %v`, redirectUri)
+	}
+
+	state, err := newAuthState()
+	if err != nil {
+		return err
+	}
+
+	redditClient := cfg.NewReddit()
+	redditClient.Uri = redirect.String()
+	redditClient.State = state
+	redditClient.Scopes = REDDIT_SCOPES
+	redditClient.DebugHTTP = debugHTTP
+
+	listener, err := net.Listen(`tcp`, redirect.Host)
+	if err != nil {
+		return fmt.Errorf(`couldn't listen on %v: %v`, redirect.Host, err)
+	}
+
+	type authResult struct {
+		code string
+		err  error
+	}
+
+	results := make(chan authResult, 1)
+
+	path := redirect.Path
+	if path == `` {
+		path = `/`
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+
+		var res authResult
+
+		if q.Get(`state`) != state {
+			res.err = fmt.Errorf(`state mismatch in redirect`)
+		} else if q.Get(`error`) != `` {
+			res.err = fmt.Errorf(`authorization error: %v`, q.Get(`error`))
+		} else if q.Get(`code`) == `` {
+			res.err = fmt.Errorf(`no code in redirect`)
+		} else {
+			res.code = q.Get(`code`)
+		}
+
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusBadRequest)
+		} else {
+			_, _ = fmt.Fprintln(w, `Authorized. You can close this window.`)
+		}
+
+		select {
+		case results <- res:
+		default:
+			// Already got a result
+		}
+	})
+
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	_, _ = fmt.Fprintf(os.Stdout, "Redirect URI %v must be set in the app settings at https://www.reddit.com/prefs/apps/\n", redirect.String())
+	_, _ = fmt.Fprintf(os.Stdout, "Open this URL in a browser logged in as the bot account:\n\n%v\n\n", redditClient.AuthorizeURL())
+
+	var res authResult
+
+	select {
+	case res = <-results:
+	case <-time.After(AUTHORIZE_TIMEOUT):
+		return fmt.Errorf(`timed out waiting for authorization`)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if res.err != nil {
+		return res.err
+	}
+
+	log.Printf(`Exchanging code for refresh token..`)
+	err = redditClient.ExchangeCode(ctx, res.code)
+	if err != nil {
+		return withExitCode(EXIT_AUTH, err)
+	}
+
+	fname := StatePath(REFRESH_TOKEN_FILE)
+
+	err = SaveRefreshToken(fname, redditClient.Token.RefreshToken)
+	if err != nil {
+		return fmt.Errorf(`couldn't save refresh token: %v`, err)
+	}
+
+	log.Printf(`Refresh token saved to %v`, fname)
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Parameters shared by commands
+type Options struct {
+	ConfigFile string
+	FeedFile   string
+	StateDir   string
+	DebugHTTP  bool
+
+	// Command specific
+	Daemon     bool
+	Redirect   string
+	FakeListen string
+	FakeFeeds  string
+}
+
+// Register shared parameters, current values are used as defaults so that parameters given before the command carry over
+func (o *Options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.ConfigFile, `config`, o.ConfigFile, `Config file name (JSON, YAML or TOML by extension) which has client secrets generated at reddit`)
+	fs.StringVar(&o.FeedFile, `feed`, o.FeedFile, `RSS feed file name (JSON, YAML or TOML by extension)`)
+	fs.StringVar(&o.StateDir, `state`, o.StateDir, `Directory for cache files and refresh token`)
+	fs.BoolVar(&o.DebugHTTP, `debug-http`, o.DebugHTTP, `Log full Reddit API response bodies (credentials are redacted)`)
+}
+
+// Load configuration, credentials and refresh token from state directory
+func (o *Options) loadConfig() (cfg Configuration, err error) {
+	log.Printf(`Loading config..`)
+	cfg, err = LoadConfig(o.ConfigFile)
+	if err != nil {
+		return cfg, withExitCode(EXIT_CONFIG, err)
+	}
+
+	sources, err := cfg.ResolveCredentials()
+	if err != nil {
+		return cfg, withExitCode(EXIT_CONFIG, fmt.Errorf(`couldn't load credentials: %w`, err))
+	}
+
+	for _, f := range cfg.credentialFields() {
+		log.Printf(`Config %v: %v`, f.key, sources[f.key])
+	}
+
+	cfg.RefreshToken, err = LoadRefreshToken(StatePath(REFRESH_TOKEN_FILE))
+	if err != nil {
+		return cfg, fmt.Errorf(`couldn't load refresh token: %v`, err)
+	}
+
+	return cfg, nil
+}
+
+// Load and validate feed configuration, errors include file name and position
+// Feeds are loaded from config file instead if it has feed configuration
+func (o *Options) loadFeeds() (feeds FeedConfig, err error) {
+	combined, err := hasFeedConfig(o.ConfigFile)
+	if err != nil {
+		return feeds, withExitCode(EXIT_CONFIG, err)
+	}
+
+	if combined {
+		if _, err := os.Stat(o.FeedFile); err == nil {
+			return feeds, withExitCode(EXIT_CONFIG, fmt.Errorf(`feeds are configured in both %v and %v, move them to one file`, o.ConfigFile, o.FeedFile))
+		}
+
+		log.Printf(`Loading feeds from %v..`, o.ConfigFile)
+		feeds, err = LoadCombinedConfig(o.ConfigFile)
+
+		return feeds, withExitCode(EXIT_CONFIG, err)
+	}
+
+	log.Printf(`Loading feeds..`)
+	feeds, err = LoadFeedConfig(o.FeedFile)
+
+	return feeds, withExitCode(EXIT_CONFIG, err)
+}
+
+// Runs command with positional arguments left after parsing command's parameters
+type CommandFunc func(ctx context.Context, args []string, errlog *log.Logger) error
+
+type Command struct {
+	Name string // Command name, two words for grouped commands such as "feeds list"
+	Args string // Positional arguments for help
+	Help string
+
+	// Register command's own parameters and return function running the command
+	Setup func(fs *flag.FlagSet, o *Options) CommandFunc
+}
+
+// Find command by first one or two arguments
+// Returns remaining arguments
+func findCommand(args []string) (*Command, []string) {
+	if len(args) >= 2 {
+		name := args[0] + ` ` + args[1]
+
+		for i := range commands {
+			if commands[i].Name == name {
+				return &commands[i], args[2:]
+			}
+		}
+	}
+
+	for i := range commands {
+		if commands[i].Name == args[0] {
+			return &commands[i], args[1:]
+		}
+	}
+
+	return nil, nil
+}
+
+func usage() {
+	_, _ = fmt.Fprintf(os.Stdout, "Simple Reddit RSS feed bot %v build %v\n", VERSION, BUILD)
+	_, _ = fmt.Fprintf(os.Stdout, "Homepage <URL: https://github.com/raspi/SimpleRedditRSSBot >\n")
+	_, _ = fmt.Fprintf(os.Stdout, "\n")
+	_, _ = fmt.Fprintf(os.Stdout, "(c) Pekka Järvinen 2018-\n")
+	_, _ = fmt.Fprintf(os.Stdout, "Usage: %v [parameters] [command] [command parameters] [arguments]\n", os.Args[0])
+	_, _ = fmt.Fprintln(os.Stdout, `Commands (default is run):`)
+
+	printCommands(os.Stdout, commands)
+
+	_, _ = fmt.Fprintf(os.Stdout, "Use %v <command> -h for command's parameters\n", os.Args[0])
+	_, _ = fmt.Fprintln(os.Stdout, `Parameters:`)
+
+	flag.VisitAll(func(f *flag.Flag) {
+		_, _ = fmt.Fprintf(os.Stdout, "  -%s\n      %s (default: %q)\n", f.Name, f.Usage, f.DefValue)
+	})
+}
+
+// Usage for unknown command, lists the group's commands if name is a command group such as "feeds"
+func groupUsage(name string) {
+	var group []Command
+
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd.Name, name+` `) {
+			group = append(group, cmd)
+		}
+	}
+
+	if len(group) == 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage()
+		return
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "Usage: %v %v <command>\n", os.Args[0], name)
+	_, _ = fmt.Fprintln(os.Stderr, `Commands:`)
+
+	printCommands(os.Stderr, group)
+}
+
+// List commands with their arguments and help in aligned columns
+func printCommands(out io.Writer, cmds []Command) {
+	w := tabwriter.NewWriter(out, 0, 4, 1, ' ', 0)
+
+	for _, cmd := range cmds {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\n", strings.TrimSpace(cmd.Name+` `+cmd.Args), cmd.Help)
+	}
+
+	_ = w.Flush()
+}
+
+func commandUsage(cmd *Command, fs *flag.FlagSet) {
+	_, _ = fmt.Fprintf(os.Stdout, "Usage: %v\n", strings.TrimSpace(fmt.Sprintf(`%v %v [parameters] %v`, os.Args[0], cmd.Name, cmd.Args)))
+	_, _ = fmt.Fprintf(os.Stdout, "%v\n", cmd.Help)
+	_, _ = fmt.Fprintln(os.Stdout, `Parameters:`)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		_, _ = fmt.Fprintf(os.Stdout, "  -%s\n      %s (default: %q)\n", f.Name, f.Usage, f.DefValue)
+	})
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var commands = []Command{
+	{Name: `run`, Help: `Fetch feeds and submit new links (default)`, Setup: setupRun},
+	{Name: `validate`, Help: `Check config and feed files without running`, Setup: setupValidate},
+	{Name: `config convert`, Args: `<input> [output]`, Help: `Convert config or feed file between JSON, YAML and TOML`, Setup: setupConfigConvert},
+	{Name: `feeds list`, Help: `List configured feeds`, Setup: setupFeedsList},
+	{Name: `feeds test`, Args: `<title or URL>`, Help: `Fetch one feed and show how its items would be submitted, Reddit is not contacted`, Setup: setupFeedsTest},
+	{Name: `login-check`, Help: `Log in to Reddit and check account, scopes and that feeds' subreddits accept posts`, Setup: setupLoginCheck},
+	{Name: `cache list`, Args: `<subreddit>`, Help: `List URLs in subreddit's submitted cache`, Setup: setupCacheList},
+	{Name: `cache add`, Args: `<subreddit> <URL>...`, Help: `Add URLs to subreddit's submitted cache so they're never submitted`, Setup: setupCacheAdd},
+	{Name: `cache remove`, Args: `<subreddit> <URL>...`, Help: `Remove URLs from subreddit's submitted cache`, Setup: setupCacheRemove},
+	{Name: `queue list`, Help: `List submission queue`, Setup: setupQueueList},
+	{Name: `queue retry`, Args: `<URL>...`, Help: `Submit failed or skipped links again on next run`, Setup: setupQueueRetry},
+	{Name: `queue remove`, Args: `<URL>...`, Help: `Remove links from submission queue`, Setup: setupQueueRemove},
+	{Name: `authorize`, Help: `Authorize the app with Reddit and store refresh token in state directory (for accounts with 2FA)`, Setup: setupAuthorize},
+	{Name: `serve-fake`, Help: `Run fake Reddit API server for offline testing`, Setup: setupServeFake},
+	{Name: `version`, Help: `Show version`, Setup: setupVersion},
+}
+
+// Check number of positional arguments
+func needArgs(args []string, min int, max int) error {
+	if len(args) < min {
+		return withExitCode(EXIT_USAGE, fmt.Errorf(`missing arguments, see -h`))
+	}
+
+	if max >= 0 && len(args) > max {
+		return withExitCode(EXIT_USAGE, fmt.Errorf(`too many arguments, see -h`))
+	}
+
+	return nil
+}
+
+func newTabWriter() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+}
+
+func setupRun(fs *flag.FlagSet, o *Options) CommandFunc {
+	fs.BoolVar(&o.Daemon, `daemon`, o.Daemon, `Keep running and poll each feed on its own interval instead of exiting after one pass`)
+
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		return runBot(ctx, o, errlog)
+	}
+}
+
+func setupValidate(fs *flag.FlagSet, o *Options) CommandFunc {
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := o.loadConfig()
+		if err != nil {
+			return err
+		}
+
+		err = cfg.ValidateConfiguration()
+		if err != nil {
+			return withExitCode(EXIT_CONFIG, fmt.Errorf(`%v: %w`, o.ConfigFile, err))
+		}
+
+		feeds, err := o.loadFeeds()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%v: OK\n", o.ConfigFile)
+
+		for _, f := range feeds.files {
+			fmt.Printf("%v: OK, %v feeds\n", f.Name, f.Feeds)
+		}
+
+		if len(feeds.files) > 1 {
+			fmt.Printf("Total %v feeds in %v files\n", len(feeds.Feeds), len(feeds.files))
+		}
+
+		return nil
+	}
+}
+
+func setupFeedsList(fs *flag.FlagSet, o *Options) CommandFunc {
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		feeds, err := o.loadFeeds()
+		if err != nil {
+			return err
+		}
+
+		feedState, err := LoadFeedState(StatePath(FEED_STATE_FILE))
+		if err != nil {
+			return err
+		}
+
+		w := newTabWriter()
+		_, _ = fmt.Fprintln(w, "TITLE\tSUBREDDIT\tKIND\tINTERVAL\tSEEN\tURL")
+
+		for _, feed := range feeds.Feeds {
+			subReddit := feed.Subreddit
+			if subReddit == `` {
+				subReddit = feeds.Subreddit
+			}
+
+			for _, target := range feed.Crosspost {
+				subReddit += `,` + target
+			}
+
+			kind := feed.Kind
+			if kind == `` {
+				kind = KIND_LINK
+			}
+
+			seen := `-`
+			if !feedState.IsNew(feed) {
+				seen = feedState.Feeds[feed.Title].Seen.Format(`2006-01-02 15:04`)
+			}
+
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", feed.Title, subReddit, kind, feed.PollInterval(), seen, feed.UrlAddress)
+		}
+
+		return w.Flush()
+	}
+}
+
+// Find feed by title or URL
+func (c *FeedConfig) FindFeed(titleOrUrl string) (feed FeedSource, ok bool) {
+	for _, feed := range c.Feeds {
+		if feed.Title == titleOrUrl || feed.UrlAddress == titleOrUrl {
+			return feed, true
+		}
+	}
+
+	return feed, false
+}
+
+func setupFeedsTest(fs *flag.FlagSet, o *Options) CommandFunc {
+	jsonOutput := fs.Bool(`json`, false, `Output JSON`)
+
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 1, 1)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := o.loadConfig()
+		if err != nil {
+			return err
+		}
+
+		feeds, err := o.loadFeeds()
+		if err != nil {
+			return err
+		}
+
+		feed, ok := feeds.FindFeed(args[0])
+		if !ok {
+			return fmt.Errorf(`no feed with title or URL %v`, args[0])
+		}
+
+		feedState, err := LoadFeedState(StatePath(FEED_STATE_FILE))
+		if err != nil {
+			return err
+		}
+
+		queue, err := LoadQueue(StatePath(QUEUE_FILE))
+		if err != nil {
+			return err
+		}
+
+		feedClient := &http.Client{
+			Timeout: time.Second * 30,
+		}
+
+		items, err := buildFeedLinks(ctx, feedClient, &feeds, feed, cfg.Retry)
+		if err != nil {
+			return err
+		}
+
+		preview, err := previewFeed(&feeds, feed, items, feedState, queue)
+		if err != nil {
+			return err
+		}
+
+		if *jsonOutput {
+			return preview.WriteJSON(os.Stdout)
+		}
+
+		return preview.WriteText(os.Stdout)
+	}
+}
+
+func setupLoginCheck(fs *flag.FlagSet, o *Options) CommandFunc {
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := o.loadConfig()
+		if err != nil {
+			return err
+		}
+
+		err = cfg.ValidateConfiguration()
+		if err != nil {
+			return withExitCode(EXIT_CONFIG, fmt.Errorf(`%v: %w`, o.ConfigFile, err))
+		}
+
+		feeds, err := o.loadFeeds()
+		if err != nil {
+			return err
+		}
+
+		redditClient := cfg.NewReddit()
+		redditClient.DebugHTTP = o.DebugHTTP
+
+		err = redditClient.Login(ctx)
+		if err != nil {
+			return withExitCode(EXIT_AUTH, fmt.Errorf(`login failed: %w`, err))
+		}
+
+		fmt.Printf("Login:      OK\n")
+
+		problems, err := loginCheck(ctx, os.Stdout, &redditClient, &feeds)
+		if err != nil {
+			return err
+		}
+
+		if problems > 0 {
+			return fmt.Errorf(`%v problems found`, problems)
+		}
+
+		return nil
+	}
+}
+
+func setupConfigConvert(fs *flag.FlagSet, o *Options) CommandFunc {
+	to := fs.String(`to`, ``, `Output format: json, yaml or toml (default is output file's extension or yaml)`)
+
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 1, 2)
+		if err != nil {
+			return err
+		}
+
+		input := args[0]
+
+		output := ``
+		if len(args) == 2 {
+			output = args[1]
+		}
+
+		format := *to
+		if format == `` {
+			format = FORMAT_YAML
+
+			if output != `` {
+				format = configFormat(output)
+			}
+		}
+
+		switch format {
+		case FORMAT_JSON, FORMAT_YAML, FORMAT_TOML:
+		default:
+			return withExitCode(EXIT_USAGE, fmt.Errorf(`unknown format %q, use %v, %v or %v`, format, FORMAT_JSON, FORMAT_YAML, FORMAT_TOML))
+		}
+
+		data, err := ioutil.ReadFile(input)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := decodeAnyConfig(input, data)
+		if err != nil {
+			return withExitCode(EXIT_CONFIG, err)
+		}
+
+		converted, err := encodeConfig(cfg, format)
+		if err != nil {
+			return err
+		}
+
+		if output == `` {
+			_, err = os.Stdout.Write(converted)
+			return err
+		}
+
+		// Never overwrite, output may be the original file with a different extension
+		if _, err := os.Stat(output); err == nil {
+			return fmt.Errorf(`%v exists already`, output)
+		}
+
+		// Config may have credentials
+		return ioutil.WriteFile(output, converted, 0600)
+	}
+}
+
+func setupCacheList(fs *flag.FlagSet, o *Options) CommandFunc {
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 1, 1)
+		if err != nil {
+			return err
+		}
+
+		submitted, err := LoadSubmitted(cacheFile(args[0]))
+		if err != nil {
+			return err
+		}
+
+		var urls []string
+		for u := range submitted {
+			urls = append(urls, u)
+		}
+
+		sort.Strings(urls)
+
+		for _, u := range urls {
+			fmt.Println(u)
+		}
+
+		return nil
+	}
+}
+
+func setupCacheAdd(fs *flag.FlagSet, o *Options) CommandFunc {
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 2, -1)
+		if err != nil {
+			return err
+		}
+
+		fname := cacheFile(args[0])
+		submitted, err := LoadSubmitted(fname)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range args[1:] {
+			submitted[u] = time.Now()
+		}
+
+		return SaveSubmitted(fname, submitted)
+	}
+}
+
+func setupCacheRemove(fs *flag.FlagSet, o *Options) CommandFunc {
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 2, -1)
+		if err != nil {
+			return err
+		}
+
+		fname := cacheFile(args[0])
+		submitted, err := LoadSubmitted(fname)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range args[1:] {
+			if _, ok := submitted[u]; !ok {
+				errlog.Printf(`warning: %v not in cache`, u)
+				continue
+			}
+
+			delete(submitted, u)
+		}
+
+		return SaveSubmitted(fname, submitted)
+	}
+}
+
+func setupQueueList(fs *flag.FlagSet, o *Options) CommandFunc {
+	status := fs.String(`status`, ``, `Only list items with this status: pending, submitted, failed or skipped`)
+	subReddit := fs.String(`subreddit`, ``, `Only list items of this subreddit`)
+
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		queue, err := LoadQueue(StatePath(QUEUE_FILE))
+		if err != nil {
+			return err
+		}
+
+		w := newTabWriter()
+		_, _ = fmt.Fprintln(w, "STATUS\tATTEMPTS\tNEXT ATTEMPT\tSUBREDDIT\tURL\tREASON")
+
+		for _, item := range queue.Items {
+			if *status != `` && item.Status != *status {
+				continue
+			}
+
+			if *subReddit != `` && !strings.EqualFold(item.Link.SubReddit, *subReddit) {
+				continue
+			}
+
+			next := `-`
+			if item.Status == STATUS_PENDING {
+				next = item.NextAttempt.Local().Format(`2006-01-02 15:04`)
+			}
+
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", item.Status, item.Attempts, next, item.Link.SubReddit, item.Link.Url, item.Reason)
+		}
+
+		return w.Flush()
+	}
+}
+
+// Apply f to queue items with given URLs and save queue
+func updateQueue(args []string, subReddit string, errlog *log.Logger, f func(q *Queue, item *QueueItem)) error {
+	queue, err := LoadQueue(StatePath(QUEUE_FILE))
+	if err != nil {
+		return err
+	}
+
+	for _, u := range args {
+		var found []*QueueItem
+
+		for _, item := range queue.Items {
+			if item.Link.Url != u {
+				continue
+			}
+
+			if subReddit != `` && !strings.EqualFold(item.Link.SubReddit, subReddit) {
+				continue
+			}
+
+			found = append(found, item)
+		}
+
+		if len(found) == 0 {
+			errlog.Printf(`warning: %v not in queue`, u)
+			continue
+		}
+
+		for _, item := range found {
+			f(queue, item)
+		}
+	}
+
+	return queue.Save()
+}
+
+func setupQueueRetry(fs *flag.FlagSet, o *Options) CommandFunc {
+	subReddit := fs.String(`subreddit`, ``, `Only retry items of this subreddit`)
+
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 1, -1)
+		if err != nil {
+			return err
+		}
+
+		return updateQueue(args, *subReddit, errlog, func(q *Queue, item *QueueItem) {
+			if item.Status == STATUS_SUBMITTED {
+				errlog.Printf(`warning: %v already submitted to %v as %v, not retrying`, item.Link.Url, item.Link.SubReddit, item.Post)
+				return
+			}
+
+			item.Retry()
+			fmt.Printf("%v %v: %v\n", item.Link.SubReddit, item.Link.Url, item.Status)
+		})
+	}
+}
+
+func setupQueueRemove(fs *flag.FlagSet, o *Options) CommandFunc {
+	subReddit := fs.String(`subreddit`, ``, `Only remove items of this subreddit`)
+
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 1, -1)
+		if err != nil {
+			return err
+		}
+
+		return updateQueue(args, *subReddit, errlog, func(q *Queue, item *QueueItem) {
+			q.Remove(item)
+			fmt.Printf("%v %v: removed\n", item.Link.SubReddit, item.Link.Url)
+		})
+	}
+}
+
+func setupAuthorize(fs *flag.FlagSet, o *Options) CommandFunc {
+	fs.StringVar(&o.Redirect, `redirect`, o.Redirect, `Redirect URI, must match the app settings at reddit`)
+
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := o.loadConfig()
+		if err != nil {
+			return err
+		}
+
+		if cfg.ClientId == `` || cfg.Secret == `` {
+			return withExitCode(EXIT_CONFIG, fmt.Errorf(`client id and secret are required for authorize`))
+		}
+
+		return Authorize(ctx, cfg, o.Redirect, o.DebugHTTP)
+	}
+}
+
+func setupServeFake(fs *flag.FlagSet, o *Options) CommandFunc {
+	fs.StringVar(&o.FakeListen, `fake-listen`, o.FakeListen, `Listen address`)
+	fs.StringVar(&o.FakeFeeds, `fake-feeds`, o.FakeFeeds, `Directory of static feed files served at /feeds/`)
+
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		err := needArgs(args, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := o.loadConfig()
+		if err != nil {
+			return err
+		}
+
+		return serveFake(cfg, o.FakeListen, o.FakeFeeds)
+	}
+}
+
+func setupVersion(fs *flag.FlagSet, o *Options) CommandFunc {
+	return func(ctx context.Context, args []string, errlog *log.Logger) error {
+		fmt.Printf("Simple Reddit RSS feed bot %v build %v (%v)\n", VERSION, BUILD, BUILDDATE)
+		return nil
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Error in configuration file
+type ConfigError struct {
+	File   string
+	Line   int // 1-based, 0 if position is unknown
+	Column int
+	Path   string // JSON path such as feeds[2].url, empty for whole file
+	Msg    string
+
+	offset int // Byte offset in file, -1 if unknown
+}
+
+func (e ConfigError) Error() string {
+	var b strings.Builder
+
+	if e.File != `` {
+		b.WriteString(e.File)
+
+		if e.Line > 0 {
+			_, _ = fmt.Fprintf(&b, `:%v:%v`, e.Line, e.Column)
+		}
+
+		b.WriteString(`: `)
+	}
+
+	if e.Path != `` {
+		b.WriteString(e.Path)
+		b.WriteString(`: `)
+	}
+
+	b.WriteString(e.Msg)
+
+	return b.String()
+}
+
+// All errors found in configuration file
+type ConfigErrors []ConfigError
+
+func (errs ConfigErrors) Error() string {
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+
+	lines := []string{fmt.Sprintf(`%v errors:`, len(errs))}
+	for _, e := range errs {
+		lines = append(lines, `  `+e.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Add error for JSON path
+func (errs *ConfigErrors) add(path string, format string, args ...interface{}) {
+	*errs = append(*errs, ConfigError{Path: path, Msg: fmt.Sprintf(format, args...), offset: -1})
+}
+
+// Is there an error for path in file
+func (errs ConfigErrors) has(fname string, path string) bool {
+	for _, e := range errs {
+		if e.File == fname && e.Path == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Nil if there are no errors, avoids returning non-nil error interface holding empty list
+func (errs ConfigErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// Set file name and line and column from JSON positions, errors are sorted by position
+func (errs ConfigErrors) locate(fname string, data []byte, positions jsonPositions) {
+	for i := range errs {
+		e := &errs[i]
+		e.File = fname
+
+		if e.offset < 0 {
+			e.offset = positions.find(e.Path)
+		}
+
+		if e.offset >= 0 {
+			e.Line, e.Column = lineColumn(data, e.offset)
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].before(errs[j])
+	})
+}
+
+// Order of errors in the same file, errors without position last
+func (e ConfigError) before(other ConfigError) bool {
+	if e.offset < 0 || other.offset < 0 {
+		return other.offset < 0 && e.offset >= 0
+	}
+
+	return e.offset < other.offset
+}
+
+// Line and column (1-based) of byte offset
+func lineColumn(data []byte, offset int) (line int, column int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
+
+// Decode JSON strictly into v
+// Syntax errors are returned as err, type errors and keys that don't match any field are all returned in errs
+func decodeStrictJSON(fname string, data []byte, v interface{}) (positions jsonPositions, errs ConfigErrors, err error) {
+	err = json.Unmarshal(data, v)
+
+	if e, ok := err.(*json.SyntaxError); ok {
+		errs = ConfigErrors{{Msg: e.Error(), offset: int(e.Offset)}}
+		errs.locate(fname, data, nil)
+		return nil, nil, errs
+	}
+
+	// Unmarshal only reports the first type error, scanner decodes each value separately to find all of them
+	s := jsonScanner{
+		data:      data,
+		positions: make(jsonPositions),
+	}
+
+	s.value(``, reflect.TypeOf(v))
+
+	errs = s.errs
+
+	if err != nil && len(errs) == 0 {
+		return nil, nil, fmt.Errorf(`%v: %v`, fname, err)
+	}
+
+	errs.locate(fname, data, s.positions)
+
+	return s.positions, errs, nil
+}
+
+// Byte offsets of JSON values by path such as feeds[2].url
+type jsonPositions map[string]int
+
+// Offset of path or its closest parent, -1 if not found
+func (p jsonPositions) find(path string) int {
+	for path != `` {
+		if offset, ok := p[path]; ok {
+			return offset
+		}
+
+		i := strings.LastIndexAny(path, `.[`)
+		if i < 0 {
+			break
+		}
+
+		path = path[:i]
+	}
+
+	return -1
+}
+
+// Walks already validated JSON and records value positions, type errors and keys not matching struct fields
+type jsonScanner struct {
+	data      []byte
+	pos       int
+	positions jsonPositions
+	errs      ConfigErrors
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n", s.data[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+// Scan value at current position, t is the Go type it's decoded to or nil if unknown
+func (s *jsonScanner) value(path string, t reflect.Type) {
+	s.skipSpace()
+
+	if s.pos >= len(s.data) {
+		return
+	}
+
+	start := s.pos
+
+	if path != `` {
+		s.positions[path] = start
+	}
+
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t != nil && !jsonContainer(t, s.data[start]) {
+		// Scalar or value of wrong kind, decoded on its own after it's scanned
+		defer s.checkType(path, start, t)
+		t = nil
+	}
+
+	switch s.data[s.pos] {
+	case '{':
+		s.pos++
+
+		for {
+			s.skipSpace()
+
+			if s.pos >= len(s.data) {
+				return
+			}
+
+			switch s.data[s.pos] {
+			case '}':
+				s.pos++
+				return
+			case ',':
+				s.pos++
+				continue
+			}
+
+			keyOffset := s.pos
+			key := s.str()
+
+			childPath := key
+			if path != `` {
+				childPath = path + `.` + key
+			}
+
+			childType, ok := jsonFieldType(t, key)
+			if !ok {
+				s.errs = append(s.errs, ConfigError{Path: childPath, Msg: fmt.Sprintf(`unknown field %q`, key), offset: keyOffset})
+			}
+
+			s.skipSpace()
+			s.pos++ // :
+
+			s.value(childPath, childType)
+		}
+	case '[':
+		s.pos++
+
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+
+		for i := 0; ; {
+			s.skipSpace()
+
+			if s.pos >= len(s.data) {
+				return
+			}
+
+			switch s.data[s.pos] {
+			case ']':
+				s.pos++
+				return
+			case ',':
+				s.pos++
+				continue
+			}
+
+			s.value(fmt.Sprintf(`%v[%v]`, path, i), elem)
+			i++
+		}
+	case '"':
+		s.str()
+	default:
+		for s.pos < len(s.data) && strings.IndexByte(",}] \t\r\n", s.data[s.pos]) < 0 {
+			s.pos++
+		}
+	}
+}
+
+// Decode value between start and current position to t and record error if it doesn't fit
+func (s *jsonScanner) checkType(path string, start int, t reflect.Type) {
+	err := json.Unmarshal(s.data[start:s.pos], reflect.New(t).Interface())
+	if err == nil {
+		return
+	}
+
+	msg := err.Error()
+	if e, ok := err.(*json.UnmarshalTypeError); ok {
+		msg = fmt.Sprintf(`expected %v, got %v`, e.Type, e.Value)
+	}
+
+	s.errs = append(s.errs, ConfigError{Path: path, Msg: msg, offset: start})
+}
+
+// Is JSON value starting with c an object or array which is scanned into Go type t field by field
+func jsonContainer(t reflect.Type, c byte) bool {
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return c == '{'
+	case reflect.Slice, reflect.Array:
+		return c == '['
+	}
+
+	return false
+}
+
+// Scan string at current position and return it unescaped
+func (s *jsonScanner) str() (str string) {
+	start := s.pos
+	s.pos++
+
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		s.pos++
+
+		if c == '\\' {
+			s.pos++
+		} else if c == '"' {
+			break
+		}
+	}
+
+	_ = json.Unmarshal(s.data[start:s.pos], &str)
+
+	return str
+}
+
+// Type of object key in t, false if t is a struct without matching field
+// Keys are matched case-insensitively like encoding/json does
+func jsonFieldType(t reflect.Type, key string) (reflect.Type, bool) {
+	if t == nil {
+		return nil, true
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem(), true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+
+			if f.PkgPath != `` {
+				// Unexported
+				continue
+			}
+
+			name := strings.Split(f.Tag.Get(`json`), `,`)[0]
+
+			if f.Anonymous && name == `` && f.Type.Kind() == reflect.Struct {
+				// Embedded struct's fields are at the same level
+				if ft, ok := jsonFieldType(f.Type, key); ok {
+					return ft, true
+				}
+
+				continue
+			}
+
+			switch name {
+			case `-`:
+				continue
+			case ``:
+				name = f.Name
+			}
+
+			if strings.EqualFold(name, key) {
+				return f.Type, true
+			}
+		}
+
+		return nil, false
+	}
+
+	return nil, true
+}
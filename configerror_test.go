@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestDecodeStrictJSONTypeErrors(t *testing.T) {
+	data := []byte(`{"feeds": [{"title": "news", "maxlen": "ten", "interval": 5, "bogus": 1}]}`)
+
+	var cfg FeedConfig
+
+	_, errs, err := decodeStrictJSON(`feeds.json`, data, &cfg)
+	if err != nil {
+		t.Fatalf(`unexpected error: %v`, err)
+	}
+
+	want := []string{
+		`feeds.json:1:40: feeds[0].maxlen: expected int, got string`,
+		`feeds.json:1:59: feeds[0].interval: expected string, got number`,
+		`feeds.json:1:62: feeds[0].bogus: unknown field "bogus"`,
+	}
+
+	if len(errs) != len(want) {
+		t.Fatalf(`got %v errors, want %v: %v`, len(errs), len(want), errs)
+	}
+
+	for i, e := range errs {
+		if e.Error() != want[i] {
+			t.Errorf(`error %v = %q, want %q`, i, e.Error(), want[i])
+		}
+	}
+
+	if len(cfg.Feeds) != 1 || cfg.Feeds[0].Title != `news` {
+		t.Errorf(`valid fields weren't decoded: %+v`, cfg.Feeds)
+	}
+}
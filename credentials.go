@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ENV_PREFIX          = `REDDITBOT_`            // Prefix for environment variable overrides
+	ENV_CREDENTIALS_DIR = `CREDENTIALS_DIRECTORY` // Set by systemd for LoadCredential=
+)
+
+// Credential in configuration and where its value can come from
+type credentialField struct {
+	key   string  // Key in config file and credential file name in $CREDENTIALS_DIRECTORY
+	env   string  // Environment variable name
+	value *string // Value
+	file  *string // Value of <key>_file in config file
+}
+
+func (c *Configuration) credentialFields() []credentialField {
+	return []credentialField{
+		{key: `user`, env: ENV_PREFIX + `USER`, value: &c.Username, file: &c.UsernameFile},
+		{key: `pass`, env: ENV_PREFIX + `PASS`, value: &c.Password, file: &c.PasswordFile},
+		{key: `cid`, env: ENV_PREFIX + `CLIENT_ID`, value: &c.ClientId, file: &c.ClientIdFile},
+		{key: `secret`, env: ENV_PREFIX + `SECRET`, value: &c.Secret, file: &c.SecretFile},
+	}
+}
+
+// Read secret from file, trailing newline is removed
+func readSecretFile(fname string) (string, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return ``, err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Resolve credentials from secret files, systemd credentials and environment variables
+// Later source overrides earlier: config value, <key>_file, $CREDENTIALS_DIRECTORY/<key>, REDDITBOT_* environment variable
+// Returns map of config key to description of the source used, values are never included
+func (c *Configuration) ResolveCredentials() (sources map[string]string, err error) {
+	sources = make(map[string]string)
+
+	credDir := os.Getenv(ENV_CREDENTIALS_DIR)
+
+	for _, f := range c.credentialFields() {
+		if *f.value != `` {
+			sources[f.key] = `config file`
+		}
+
+		if *f.file != `` {
+			v, err := readSecretFile(*f.file)
+			if err != nil {
+				return sources, fmt.Errorf(`couldn't read %v_file: %v`, f.key, err)
+			}
+
+			*f.value = v
+			sources[f.key] = fmt.Sprintf(`file %v`, *f.file)
+		}
+
+		if credDir != `` {
+			fname := filepath.Join(credDir, f.key)
+
+			v, err := readSecretFile(fname)
+			if err == nil {
+				*f.value = v
+				sources[f.key] = fmt.Sprintf(`credential %v`, fname)
+			} else if !os.IsNotExist(err) {
+				return sources, fmt.Errorf(`couldn't read credential %v: %v`, fname, err)
+			}
+		}
+
+		if v, ok := os.LookupEnv(f.env); ok && v != `` {
+			*f.value = v
+			sources[f.key] = fmt.Sprintf(`environment %v`, f.env)
+		}
+
+		if _, ok := sources[f.key]; !ok {
+			sources[f.key] = `not set`
+		}
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	DAEMON_PAUSE     = QUEUE_RETRY_DELAY // Wait before submitting again after rate limit or failed login
+	DAEMON_MIN_SLEEP = time.Second       // Shortest sleep between scheduler rounds
+)
+
+// Long-running mode: polls each feed on its own interval and drains the queue when items are due
+// Reddit client and its access token are kept between rounds
+type Daemon struct {
+	Feeds      *FeedConfig
+	FeedState  *FeedState
+	FeedClient *http.Client
+	Submitter  *Submitter
+	Retry      RetryPolicy // Retry policy for feed requests
+
+	nextPoll    []time.Time // Next poll time per feed
+	pausedUntil time.Time   // No submits before this
+	errlog      *log.Logger
+}
+
+// Poll feeds whose interval has passed and add new links to queue
+func (d *Daemon) poll(ctx context.Context, now time.Time) {
+	var links []SubmitLink
+
+	for i, feedSource := range d.Feeds.Feeds {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if d.nextPoll[i].After(now) {
+			continue
+		}
+
+		log.Printf(`Polling feed '%v'..`, feedSource.Title)
+		// Failed feeds are logged and polled again on their next interval
+		feedLinks, _ := pollFeed(ctx, d.FeedClient, d.Feeds, d.FeedState, feedSource, d.Retry, d.errlog)
+		links = append(links, feedLinks...)
+		d.nextPoll[i] = now.Add(feedSource.PollInterval())
+	}
+
+	if len(links) == 0 {
+		return
+	}
+
+	added, err := enqueueLinks(d.Submitter.Queue, links)
+	if err != nil {
+		d.errlog.Printf(`error: %v`, err)
+	}
+
+	if added == 0 {
+		return
+	}
+
+	err = d.Submitter.Queue.Save()
+	if err != nil {
+		d.errlog.Printf(`error: saving queue: %v`, err)
+	}
+
+	log.Printf(`Added %v URLs to queue`, added)
+}
+
+// Submit due queue items unless paused
+func (d *Daemon) submit(ctx context.Context, now time.Time) {
+	if d.pausedUntil.After(now) {
+		return
+	}
+
+	err := d.Submitter.SubmitDue(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+
+		d.errlog.Printf(`error: %v, pausing submits until %v`, err, now.Add(DAEMON_PAUSE).Format(time.RFC3339))
+		d.pausedUntil = now.Add(DAEMON_PAUSE)
+		return
+	}
+
+	if d.Submitter.Stopped {
+		log.Printf(`Pausing submits until %v`, now.Add(DAEMON_PAUSE).Format(time.RFC3339))
+		d.pausedUntil = now.Add(DAEMON_PAUSE)
+	}
+}
+
+// Time of next scheduled feed poll or queue item
+func (d *Daemon) wakeUp(now time.Time) time.Time {
+	var wake time.Time
+
+	for i, next := range d.nextPoll {
+		if i == 0 || next.Before(wake) {
+			wake = next
+		}
+	}
+
+	due, ok := d.Submitter.Queue.NextDue()
+	if ok {
+		if due.Before(d.pausedUntil) {
+			due = d.pausedUntil
+		}
+
+		if wake.IsZero() || due.Before(wake) {
+			wake = due
+		}
+	}
+
+	if wake.IsZero() || wake.Before(now.Add(DAEMON_MIN_SLEEP)) {
+		wake = now.Add(DAEMON_MIN_SLEEP)
+	}
+
+	return wake
+}
+
+// Run scheduler until ctx is cancelled
+func (d *Daemon) Run(ctx context.Context) {
+	d.nextPoll = make([]time.Time, len(d.Feeds.Feeds))
+
+	log.Printf(`Running as daemon with %v feeds`, len(d.Feeds.Feeds))
+
+	for ctx.Err() == nil {
+		d.poll(ctx, time.Now())
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		d.submit(ctx, time.Now())
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		timer := time.NewTimer(time.Until(d.wakeUp(time.Now())))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+
+	log.Printf(`Stopped`)
+}
@@ -0,0 +1,705 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/raspi/SimpleRedditRSSBot/fakereddit"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Reddit client against fakereddit
+// Auth and API are separate servers which only serve their own endpoints so that both base URLs are tested
+// wrap can intercept requests before fakereddit, nil passes them through
+func newFakeReddit(t *testing.T, wrap func(http.Handler) http.Handler) (srv *fakereddit.Server, redditClient Reddit, closeFn func()) {
+	srv = fakereddit.New(`bot`, `pass`, `cid`, `secret`)
+
+	handler := srv.Handler()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+
+	only := func(auth bool) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if (req.URL.Path == `/api/v1/access_token`) != auth {
+				t.Errorf(`%v requested from wrong server`, req.URL.Path)
+				http.NotFound(w, req)
+				return
+			}
+
+			handler.ServeHTTP(w, req)
+		})
+	}
+
+	authSrv := httptest.NewServer(only(true))
+	apiSrv := httptest.NewServer(only(false))
+
+	closeFn = func() {
+		authSrv.Close()
+		apiSrv.Close()
+	}
+
+	cfg := Configuration{
+		Username: `bot`,
+		Password: `pass`,
+		ClientId: `cid`,
+		Secret:   `secret`,
+		AuthUrl:  authSrv.URL + `/`,
+		ApiUrl:   apiSrv.URL,
+		Retry:    RetryPolicy{Delay: `1ms`, MaxDelay: `1ms`},
+	}
+
+	return srv, cfg.NewReddit(), closeFn
+}
+
+func TestFakeRedditLogin(t *testing.T) {
+	ctx := context.Background()
+	_, redditClient, closeFn := newFakeReddit(t, nil)
+	defer closeFn()
+
+	redditClient.Password = `wrong`
+
+	err := redditClient.Login(ctx)
+	if err == nil || !strings.Contains(err.Error(), `invalid_grant`) {
+		t.Fatalf(`password login with wrong password: got %v, want invalid_grant`, err)
+	}
+
+	redditClient.Password = `pass`
+
+	err = redditClient.Login(ctx)
+	if err != nil {
+		t.Fatalf(`password login: %v`, err)
+	}
+
+	if redditClient.Token.Id == `` {
+		t.Fatalf(`no access token after login`)
+	}
+
+	// Refresh token is only given in authorization code flow
+	err = redditClient.ExchangeCode(ctx, `code`)
+	if err != nil {
+		t.Fatalf(`exchanging code: %v`, err)
+	}
+
+	refreshed := redditClient
+	refreshed.Username = ``
+	refreshed.Password = ``
+	refreshed.Token = RedditAccessToken{RefreshToken: redditClient.Token.RefreshToken}
+
+	err = refreshed.Login(ctx)
+	if err != nil {
+		t.Fatalf(`refresh token login: %v`, err)
+	}
+
+	refreshed.Token = RedditAccessToken{RefreshToken: `revoked`}
+
+	err = refreshed.Login(ctx)
+	if err == nil || !strings.Contains(err.Error(), `invalid_grant`) {
+		t.Fatalf(`login with revoked refresh token: got %v, want invalid_grant`, err)
+	}
+}
+
+func TestFakeRedditSubmit(t *testing.T) {
+	ctx := context.Background()
+	srv, redditClient, closeFn := newFakeReddit(t, nil)
+	defer closeFn()
+
+	err := redditClient.Login(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post, err := redditClient.SubmitLink(ctx, SubmitLink{Title: `First story`, Url: `https://example.com/1`, SubReddit: `test`, Kind: KIND_LINK, FlairId: `6f2b7c3e-1a2b-11e8-9c4d-0e1a2b3c4d5e`, Flair: `News`})
+	if err != nil {
+		t.Fatalf(`link post: %v`, err)
+	}
+
+	_, err = redditClient.SubmitLink(ctx, SubmitLink{Title: `Second story`, Text: `Summary`, SubReddit: `test`, Kind: KIND_SELF})
+	if err != nil {
+		t.Fatalf(`text post: %v`, err)
+	}
+
+	comment, err := redditClient.Comment(ctx, post.Name, `Source`)
+	if err != nil {
+		t.Fatalf(`comment: %v`, err)
+	}
+
+	err = redditClient.Distinguish(ctx, comment, true)
+	if err != nil {
+		t.Fatalf(`distinguish: %v`, err)
+	}
+
+	posts := srv.Posts()
+	if len(posts) != 2 {
+		t.Fatalf(`got %v posts, want 2: %+v`, len(posts), posts)
+	}
+
+	if p := posts[0]; p.Name != post.Name || p.Kind != KIND_LINK || p.SubReddit != `test` || p.Url != `https://example.com/1` || p.Author != `bot` || p.FlairId != `6f2b7c3e-1a2b-11e8-9c4d-0e1a2b3c4d5e` || p.FlairText != `News` {
+		t.Errorf(`unexpected link post: %+v`, p)
+	}
+
+	if p := posts[1]; p.Kind != KIND_SELF || p.Title != `Second story` || p.Text != `Summary` || p.FlairId != `` {
+		t.Errorf(`unexpected text post: %+v`, p)
+	}
+
+	comments := srv.Comments()
+	if len(comments) != 1 || comments[0].Parent != post.Name || !comments[0].Distinguished || !comments[0].Stickied {
+		t.Errorf(`unexpected comments: %+v`, comments)
+	}
+
+	found, err := redditClient.FindSubmissions(ctx, `https://example.com/1`)
+	if err != nil {
+		t.Fatalf(`finding submissions: %v`, err)
+	}
+
+	if len(found) != 1 || found[0].Name != post.Name {
+		t.Errorf(`found %+v, want %v`, found, post.Name)
+	}
+}
+
+func TestFakeRedditFaults(t *testing.T) {
+	tests := []struct {
+		kind  string
+		check func(err error) bool
+	}{
+		{
+			kind: fakereddit.FAULT_ALREADY_SUB,
+			check: func(err error) bool {
+				_, ok := err.(*ErrorSubmitExists)
+				return ok
+			},
+		},
+		{
+			kind: fakereddit.FAULT_RATELIMIT,
+			check: func(err error) bool {
+				e, ok := err.(*ErrorSubmit)
+				return ok && e.RateLimited()
+			},
+		},
+		{
+			kind: fakereddit.FAULT_UNAUTHORIZED,
+			check: func(err error) bool {
+				e, ok := err.(*ErrorAPI)
+				return ok && e.status == http.StatusUnauthorized
+			},
+		},
+		{
+			kind: fakereddit.FAULT_SERVER_ERROR,
+			check: func(err error) bool {
+				return err != nil && strings.Contains(err.Error(), `status: 502`)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.kind, func(t *testing.T) {
+			ctx := context.Background()
+			srv, redditClient, closeFn := newFakeReddit(t, nil)
+			defer closeFn()
+
+			err := redditClient.Login(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Server error fails until cleared so that retries don't hide it
+			count := 1
+			if test.kind == fakereddit.FAULT_SERVER_ERROR {
+				count = 0
+			}
+
+			srv.AddFault(fakereddit.Fault{Kind: test.kind, Status: http.StatusBadGateway, Count: count})
+
+			link := SubmitLink{Title: `Story`, Url: `https://example.com/1`, SubReddit: `test`, Kind: KIND_LINK}
+
+			_, err = redditClient.SubmitLink(ctx, link)
+			if !test.check(err) {
+				t.Errorf(`got %T %v`, err, err)
+			}
+
+			if len(srv.Posts()) != 0 {
+				t.Errorf(`post was created: %+v`, srv.Posts())
+			}
+
+			srv.ClearFaults()
+
+			_, err = redditClient.SubmitLink(ctx, link)
+			if err != nil || len(srv.Posts()) != 1 {
+				t.Errorf(`submit after fault: %v, %v posts`, err, len(srv.Posts()))
+			}
+		})
+	}
+}
+
+func TestFakeRedditRetry(t *testing.T) {
+	ctx := context.Background()
+	srv, redditClient, closeFn := newFakeReddit(t, nil)
+	defer closeFn()
+
+	err := redditClient.Login(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.AddFault(fakereddit.Fault{Kind: fakereddit.FAULT_SERVER_ERROR, Count: 2})
+
+	_, err = redditClient.FindSubmissions(ctx, `https://example.com/1`)
+	if err != nil {
+		t.Errorf(`two server errors weren't retried: %v`, err)
+	}
+
+	srv.AddFault(fakereddit.Fault{Kind: fakereddit.FAULT_SERVER_ERROR, Count: DEFAULT_RETRY_ATTEMPTS})
+
+	_, err = redditClient.FindSubmissions(ctx, `https://example.com/1`)
+	if err == nil {
+		t.Errorf(`request succeeded after all attempts failed`)
+	}
+
+	// Submit may have been created even if the response was lost, so it isn't retried
+	srv.AddFault(fakereddit.Fault{Kind: fakereddit.FAULT_SERVER_ERROR, Count: 1})
+
+	_, err = redditClient.SubmitLink(ctx, SubmitLink{Title: `Story`, Url: `https://example.com/1`, SubReddit: `test`, Kind: KIND_LINK})
+	if err == nil || len(srv.Posts()) != 0 {
+		t.Errorf(`submit was retried after server error`)
+	}
+}
+
+// Submitter with queue against logged in fakereddit, state is kept in a temporary directory
+type fakeBot struct {
+	srv       *fakereddit.Server
+	reddit    Reddit
+	feeds     FeedConfig
+	queue     *Queue
+	submitter *Submitter
+	dir       string
+	closeFn   func()
+}
+
+func newFakeBot(t *testing.T, wrap func(http.Handler) http.Handler) *fakeBot {
+	dir, err := ioutil.TempDir(``, `fakebot`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &fakeBot{dir: dir}
+
+	srv, redditClient, closeReddit := newFakeReddit(t, wrap)
+	b.srv = srv
+	b.reddit = redditClient
+
+	oldStateDir := stateDir
+	stateDir = dir
+
+	b.closeFn = func() {
+		closeReddit()
+		stateDir = oldStateDir
+		os.RemoveAll(dir)
+	}
+
+	err = b.reddit.Login(context.Background())
+	if err != nil {
+		b.Close()
+		t.Fatal(err)
+	}
+
+	b.queue, err = LoadQueue(StatePath(QUEUE_FILE))
+	if err != nil {
+		b.Close()
+		t.Fatal(err)
+	}
+
+	b.submitter = &Submitter{
+		Reddit:          &b.reddit,
+		Queue:           b.queue,
+		Feeds:           &b.feeds,
+		QuarantineAfter: DEFAULT_QUARANTINE_AFTER,
+		errlog:          log.New(ioutil.Discard, ``, 0),
+	}
+
+	return b
+}
+
+func (b *fakeBot) Close() {
+	b.closeFn()
+}
+
+// Queue link post and save the queue
+func (b *fakeBot) queueLink(t *testing.T, title string) *QueueItem {
+	item := b.queue.Add(SubmitLink{
+		Title:     title,
+		Url:       `https://example.com/` + strings.ReplaceAll(title, ` `, `-`),
+		SubReddit: `test`,
+		Kind:      KIND_LINK,
+		Published: time.Now(),
+	})
+
+	if item == nil {
+		t.Fatalf(`%v already in queue`, title)
+	}
+
+	err := b.queue.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return item
+}
+
+// Submit all due queue items, logs in again if needed
+func (b *fakeBot) run(t *testing.T) {
+	err := b.submitter.SubmitDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeRedditQueue(t *testing.T) {
+	b := newFakeBot(t, nil)
+	defer b.Close()
+
+	link := b.queueLink(t, `First story`)
+	link.Link.Comment = `Source`
+	link.Link.Sticky = true
+
+	text := b.queue.Add(SubmitLink{Title: `Second story`, Text: `Summary`, Url: `https://example.com/2`, SubReddit: `test`, Kind: KIND_SELF})
+
+	b.run(t)
+
+	posts := b.srv.Posts()
+	if len(posts) != 2 {
+		t.Fatalf(`got %v posts, want 2: %+v`, len(posts), posts)
+	}
+
+	if link.Status != STATUS_SUBMITTED || link.Post != posts[0].Name || text.Status != STATUS_SUBMITTED || text.Post != posts[1].Name {
+		t.Errorf(`items not submitted: %+v %+v`, link, text)
+	}
+
+	comments := b.srv.Comments()
+	if len(comments) != 1 || comments[0].Parent != link.Post || !comments[0].Stickied {
+		t.Errorf(`unexpected comments: %+v`, comments)
+	}
+
+	saved, err := LoadQueue(StatePath(QUEUE_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range saved.Items {
+		if item.Status != STATUS_SUBMITTED {
+			t.Errorf(`saved %v has status %v`, item.Link.Url, item.Status)
+		}
+	}
+
+	// Submitted links are remembered and not queued again
+	added, err := enqueueLinks(b.queue, []SubmitLink{link.Link, text.Link})
+	if err != nil || added != 0 || len(b.queue.Due(time.Now())) != 0 {
+		t.Errorf(`submitted links were queued again`)
+	}
+}
+
+// Faults on the first of two queued links
+func TestFakeRedditQueueFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    fakereddit.Fault
+		title    string
+		status   string
+		attempts int
+		stopped  bool
+	}{
+		{name: `already_sub`, fault: fakereddit.Fault{Kind: fakereddit.FAULT_ALREADY_SUB, Count: 1}, status: STATUS_SKIPPED},
+		{name: `ratelimit`, fault: fakereddit.Fault{Kind: fakereddit.FAULT_RATELIMIT, Count: 1}, status: STATUS_PENDING, stopped: true},
+		{name: `unauthorized`, fault: fakereddit.Fault{Kind: fakereddit.FAULT_UNAUTHORIZED, Count: 1}, status: STATUS_PENDING, stopped: true},
+		{name: `server_error`, fault: fakereddit.Fault{Kind: fakereddit.FAULT_SERVER_ERROR, Count: 1}, status: STATUS_PENDING, stopped: true},
+		{name: `rejected`, title: strings.Repeat(`x`, 301), status: STATUS_PENDING, attempts: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newFakeBot(t, nil)
+			defer b.Close()
+
+			title := test.title
+			if title == `` {
+				title = `Story`
+			}
+
+			item := b.queueLink(t, title)
+			next := b.queueLink(t, `Next story`)
+
+			if test.fault.Kind != `` {
+				b.srv.AddFault(test.fault)
+			}
+
+			b.run(t)
+
+			if item.Status != test.status || item.Attempts != test.attempts {
+				t.Errorf(`item status %v attempts %v, want %v and %v`, item.Status, item.Attempts, test.status, test.attempts)
+			}
+
+			if stopped := next.Status == STATUS_PENDING; stopped != test.stopped {
+				t.Errorf(`stopped = %v, want %v`, stopped, test.stopped)
+			}
+
+			// Revoked token is dropped so that next run logs in again
+			if expired := b.reddit.TokenExpired(); expired != (test.fault.Kind == fakereddit.FAULT_UNAUTHORIZED) {
+				t.Errorf(`token expired = %v after %v`, expired, test.name)
+			}
+
+			if test.attempts > 0 && b.submitter.Failed != 1 {
+				t.Errorf(`%v failed submits counted, want 1`, b.submitter.Failed)
+			}
+
+			saved, err := LoadQueue(StatePath(QUEUE_FILE))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(saved.Items) != 2 || saved.Items[0].Status != item.Status || saved.Items[0].Attempts != item.Attempts {
+				t.Errorf(`saved queue doesn't match: %+v`, saved.Items)
+			}
+
+			if !test.stopped {
+				return
+			}
+
+			// Items are submitted on next run
+			b.run(t)
+
+			if item.Status != STATUS_SUBMITTED || next.Status != STATUS_SUBMITTED || len(b.srv.Posts()) != 2 {
+				t.Errorf(`item status %v and %v with %v posts after next run, want both submitted`, item.Status, next.Status, len(b.srv.Posts()))
+			}
+		})
+	}
+}
+
+// Run the bot once like the run command does, with config and feed files in state directory
+// Feed items link to the feed server so that no DNS lookup is needed
+func (b *fakeBot) runBot(t *testing.T, password string, titles ...string) error {
+	feedSrv := httptest.NewServer(http.FileServer(http.Dir(b.dir)))
+	defer feedSrv.Close()
+
+	var items strings.Builder
+
+	for i, title := range titles {
+		fmt.Fprintf(&items, `<item><title>%v</title><link>http://127.0.0.1/story/%v</link><pubDate>%v</pubDate></item>`,
+			title, strings.ReplaceAll(title, ` `, `-`), time.Now().Add(time.Duration(i)*time.Second).Format(time.RFC1123Z))
+	}
+
+	rss := fmt.Sprintf(`<?xml version="1.0"?><rss version="2.0"><channel><title>Test</title><link>http://127.0.0.1/</link><description>Test feed</description>%v</channel></rss>`, items.String())
+
+	cfg := fmt.Sprintf(`{"user": "bot", "pass": %q, "cid": "cid", "secret": "secret", "auth_url": %q, "api_url": %q, "retry": {"delay": "1ms", "max_delay": "1ms"}}`,
+		password, b.reddit.AuthUrl, b.reddit.ApiUrl)
+
+	o := Options{
+		ConfigFile: filepath.Join(b.dir, `config.json`),
+		FeedFile:   filepath.Join(b.dir, `feeds.json`),
+		StateDir:   b.dir,
+	}
+
+	files := map[string]string{
+		o.ConfigFile:                     cfg,
+		o.FeedFile:                       `{"subreddit": "test", "feeds": [{"title": "Test", "url": "` + feedSrv.URL + `/feed.xml"}]}`,
+		filepath.Join(b.dir, `feed.xml`): rss,
+	}
+
+	for fname, data := range files {
+		err := ioutil.WriteFile(fname, []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return runBot(context.Background(), &o, b.submitter.errlog)
+}
+
+func TestRunBotExitCodes(t *testing.T) {
+	b := newFakeBot(t, nil)
+	defer b.Close()
+
+	tests := []struct {
+		name     string
+		password string
+		titles   []string
+		fault    string
+		code     int
+		posts    int
+	}{
+		{name: `wrong password`, password: `wrong`, titles: []string{`First story`}, code: EXIT_AUTH},
+		{name: `submitted`, password: `pass`, titles: []string{`First story`}, code: EXIT_OK, posts: 1},
+		{name: `rejected`, password: `pass`, titles: []string{`First story`, strings.Repeat(`x`, 301)}, code: EXIT_PARTIAL, posts: 1},
+		{name: `nothing new`, password: `pass`, titles: []string{`First story`}, code: EXIT_OK, posts: 1},
+		{name: `rate limited`, password: `pass`, titles: []string{`First story`, `Third story`}, fault: fakereddit.FAULT_RATELIMIT, code: EXIT_PARTIAL, posts: 1},
+		{name: `after rate limit`, password: `pass`, titles: []string{`First story`, `Third story`}, code: EXIT_OK, posts: 2},
+	}
+
+	for _, test := range tests {
+		if test.fault != `` {
+			b.srv.AddFault(fakereddit.Fault{Kind: test.fault, Count: 1})
+		}
+
+		err := b.runBot(t, test.password, test.titles...)
+		if code := exitCode(err); code != test.code {
+			t.Errorf(`%v: exit code %v (%v), want %v`, test.name, code, err, test.code)
+		}
+
+		if posts := len(b.srv.Posts()); posts != test.posts {
+			t.Errorf(`%v: %v posts, want %v`, test.name, posts, test.posts)
+		}
+	}
+
+	// Broken feed file
+	err := ioutil.WriteFile(filepath.Join(b.dir, `feeds.json`), []byte(`{"feeds": [`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := Options{ConfigFile: filepath.Join(b.dir, `config.json`), FeedFile: filepath.Join(b.dir, `feeds.json`), StateDir: b.dir}
+
+	err = runBot(context.Background(), &o, b.submitter.errlog)
+	if code := exitCode(err); code != EXIT_CONFIG {
+		t.Errorf(`broken feed file: exit code %v (%v), want %v`, code, err, EXIT_CONFIG)
+	}
+}
+
+// Response to a submit which Reddit created is lost, submitting again must not duplicate the post
+func TestFakeRedditLostSubmitResponse(t *testing.T) {
+	for _, kind := range []string{KIND_LINK, KIND_SELF} {
+		t.Run(kind, func(t *testing.T) {
+			lose := 1
+
+			b := newFakeBot(t, func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					if req.URL.Path != `/api/submit` || lose == 0 {
+						next.ServeHTTP(w, req)
+						return
+					}
+
+					lose--
+
+					next.ServeHTTP(httptest.NewRecorder(), req)
+					http.Error(w, `bad gateway`, http.StatusBadGateway)
+				})
+			})
+			defer b.Close()
+
+			item := b.queueLink(t, `Story`)
+			item.Link.Kind = kind
+
+			if kind == KIND_SELF {
+				item.Link.Text = `Summary`
+			}
+
+			b.run(t)
+
+			if item.Status != STATUS_PENDING || item.Attempts != 0 {
+				t.Fatalf(`after lost response: status %v attempts %v, want pending without failed attempt`, item.Status, item.Attempts)
+			}
+
+			b.run(t)
+
+			posts := b.srv.Posts()
+			if len(posts) != 1 {
+				t.Fatalf(`got %v posts, want 1`, len(posts))
+			}
+
+			if item.Status != STATUS_SUBMITTED || item.Post != posts[0].Name {
+				t.Errorf(`item status %v post %v, want submitted as %v`, item.Status, item.Post, posts[0].Name)
+			}
+		})
+	}
+}
+
+// Rate limited first comment is posted on next run without submitting the link again
+func TestFakeRedditCommentRateLimited(t *testing.T) {
+	limited := 1
+
+	b := newFakeBot(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != `/api/comment` || limited == 0 {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			limited--
+
+			w.Header().Set(`Content-Type`, `application/json`)
+			_, _ = w.Write([]byte(`{"json": {"errors": [["RATELIMIT", "you are doing that too much", "ratelimit"]]}}`))
+		})
+	})
+	defer b.Close()
+
+	item := b.queueLink(t, `Story`)
+	item.Link.Comment = `First comment`
+
+	b.run(t)
+
+	if item.Status != STATUS_SUBMITTED || !item.CommentDue || len(b.srv.Comments()) != 0 {
+		t.Fatalf(`after rate limited comment: status %v comment due %v comments %v, want submitted with comment due`, item.Status, item.CommentDue, len(b.srv.Comments()))
+	}
+
+	b.run(t)
+
+	comments := b.srv.Comments()
+	if len(b.srv.Posts()) != 1 || len(comments) != 1 || comments[0].Parent != item.Post || item.CommentDue {
+		t.Errorf(`after next run: %v posts, comments %+v, comment due %v, want one post with one comment`, len(b.srv.Posts()), comments, item.CommentDue)
+	}
+}
+
+// Password login grants all scopes, the scopes the bot uses are still described
+func TestLoginCheckScopes(t *testing.T) {
+	b := newFakeBot(t, nil)
+	defer b.Close()
+
+	var out bytes.Buffer
+
+	problems, err := loginCheck(context.Background(), &out, &b.reddit, &FeedConfig{})
+	if err != nil || problems != 0 {
+		t.Fatalf(`login check: %v problems, %v`, problems, err)
+	}
+
+	for _, want := range []string{`Scopes:     * (all)`, `submit       Submit links and comments from my account.`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf(`output doesn't have %q:\n%v`, want, out.String())
+		}
+	}
+}
+
+// Waiting for Retry-After longer than max delay would only delay the whole run
+func TestFakeRedditRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	requests := 0
+
+	_, redditClient, closeFn := newFakeReddit(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != `/api/info` {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			requests++
+
+			w.Header().Set(`Retry-After`, `3600`)
+			http.Error(w, `too many requests`, http.StatusTooManyRequests)
+		})
+	})
+	defer closeFn()
+
+	err := redditClient.Login(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = redditClient.FindSubmissions(ctx, `https://example.com/1`)
+	if err == nil || requests != 1 {
+		t.Errorf(`got %v after %v requests, want error without retrying`, err, requests)
+	}
+}
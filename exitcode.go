@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+)
+
+// Exit codes, so that systemd and alerting can tell failures apart
+const (
+	EXIT_OK          = 0   // Success
+	EXIT_ERROR       = 1   // Other errors, for example state files couldn't be read or written
+	EXIT_USAGE       = 2   // Unknown command, parameters or arguments
+	EXIT_CONFIG      = 3   // Invalid or unreadable config.json or feeds.json
+	EXIT_AUTH        = 4   // Logging in to Reddit failed
+	EXIT_PARTIAL     = 5   // Run finished but some feeds or submits failed
+	EXIT_INTERRUPTED = 130 // Stopped by SIGINT or SIGTERM
+)
+
+// Error with exit code of its class
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Set error's exit code, nil stays nil
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &ExitError{Code: code, Err: err}
+}
+
+// Exit code for error returned by command
+func exitCode(err error) int {
+	if err == nil {
+		return EXIT_OK
+	}
+
+	var e *ExitError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return EXIT_ERROR
+}
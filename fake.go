@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"github.com/raspi/SimpleRedditRSSBot/fakereddit"
+	"log"
+	"net/http"
+)
+
+const DEFAULT_FAKE_LISTEN = `127.0.0.1:8081` // Default listen address for serve-fake command
+
+// Run fake Reddit server which accepts the credentials in configuration
+// Point auth_url and api_url in configuration to the listen address to use it
+func serveFake(cfg Configuration, listen string, feedDir string) error {
+	srv := fakereddit.New(cfg.Username, cfg.Password, cfg.ClientId, cfg.Secret)
+	srv.FeedDir = feedDir
+
+	base := fmt.Sprintf(`http://%v`, listen)
+
+	log.Printf(`Fake Reddit listening on %v, set "auth_url" and "api_url" to it`, base)
+	log.Printf(`Inject faults with: curl -d kind=ratelimit -d count=1 %v/_fake/faults`, base)
+
+	if feedDir != `` {
+		log.Printf(`Serving feeds from %v at %v/feeds/`, feedDir, base)
+	}
+
+	return http.ListenAndServe(listen, srv.Handler())
+}
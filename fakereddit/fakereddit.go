@@ -0,0 +1,772 @@
+// Package fakereddit implements the parts of Reddit's API used by the bot with in-memory state.
+// It's meant for offline end-to-end testing: point the bot's auth_url and api_url at the server.
+package fakereddit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault kinds which can be injected
+const (
+	FAULT_ALREADY_SUB  = `already_sub`  // Submit fails with ALREADY_SUB
+	FAULT_RATELIMIT    = `ratelimit`    // Submit and comment fail with RATELIMIT
+	FAULT_UNAUTHORIZED = `unauthorized` // OAuth API calls fail with 401
+	FAULT_SERVER_ERROR = `server_error` // All calls fail with 5xx status
+)
+
+// Submitted post
+type Post struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	SubReddit string    `json:"subreddit"`
+	Title     string    `json:"title"`
+	Url       string    `json:"url,omitempty"`
+	Text      string    `json:"text,omitempty"`
+	FlairId   string    `json:"flair_id,omitempty"`
+	FlairText string    `json:"flair_text,omitempty"`
+	Crosspost string    `json:"crosspost_parent,omitempty"`
+	Author    string    `json:"author"`
+	Created   time.Time `json:"created"`
+}
+
+// Posted comment
+type Comment struct {
+	Id            string `json:"id"`
+	Name          string `json:"name"`
+	Parent        string `json:"parent_id"`
+	Body          string `json:"body"`
+	Author        string `json:"author"`
+	Distinguished bool   `json:"distinguished"`
+	Stickied      bool   `json:"stickied"`
+}
+
+// Link flair template of a subreddit
+type Flair struct {
+	Id           string `json:"id"`
+	Text         string `json:"text"`
+	TextEditable bool   `json:"text_editable"`
+	Type         string `json:"type"`
+}
+
+// Injected fault
+type Fault struct {
+	Kind   string `json:"kind"`
+	Status int    `json:"status,omitempty"` // HTTP status for server_error, default 503
+	Count  int    `json:"count"`            // How many requests fail, 0 fails until cleared
+}
+
+// Fake Reddit server
+type Server struct {
+	// Accepted credentials, empty value accepts anything
+	Username string
+	Password string
+	ClientId string
+	Secret   string
+
+	Flairs  map[string][]Flair // Link flairs per lower case subreddit name
+	FeedDir string             // Directory served at /feeds/ if not empty
+
+	mu            sync.Mutex
+	posts         []Post
+	comments      []Comment
+	tokens        map[string]string // access token -> user name
+	refreshTokens map[string]string // refresh token -> user name
+	faults        []Fault
+	nextId        int
+}
+
+// Create new server which accepts given credentials
+func New(username, password, clientId, secret string) *Server {
+	return &Server{
+		Username:      username,
+		Password:      password,
+		ClientId:      clientId,
+		Secret:        secret,
+		Flairs:        make(map[string][]Flair),
+		tokens:        make(map[string]string),
+		refreshTokens: make(map[string]string),
+	}
+}
+
+// HTTP handler for both auth (www.reddit.com) and API (oauth.reddit.com) endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(`/api/v1/access_token`, s.handleAccessToken)
+	mux.HandleFunc(`/api/v1/authorize`, s.handleAuthorize)
+	mux.HandleFunc(`/api/v1/me`, s.oauth(s.handleMe))
+	mux.HandleFunc(`/api/v1/scopes`, s.oauth(s.handleScopes))
+	mux.HandleFunc(`/api/submit`, s.oauth(s.handleSubmit))
+	mux.HandleFunc(`/api/comment`, s.oauth(s.handleComment))
+	mux.HandleFunc(`/api/distinguish`, s.oauth(s.handleDistinguish))
+	mux.HandleFunc(`/api/info`, s.oauth(s.handleInfo))
+	mux.HandleFunc(`/r/`, s.oauth(s.handleSubreddit))
+	mux.HandleFunc(`/user/`, s.oauth(s.handleUser))
+	mux.HandleFunc(`/_fake/faults`, s.handleFaults)
+	mux.HandleFunc(`/_fake/state`, s.handleState)
+	mux.HandleFunc(`/_fake/reset`, s.handleReset)
+
+	if s.FeedDir != `` {
+		mux.Handle(`/feeds/`, http.StripPrefix(`/feeds/`, http.FileServer(http.Dir(s.FeedDir))))
+	}
+
+	return formDefaults(mux)
+}
+
+// Reddit parses POST bodies as forms even without Content-Type
+func formDefaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == `POST` && req.Header.Get(`Content-Type`) == `` {
+			req.Header.Set(`Content-Type`, `application/x-www-form-urlencoded`)
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Inject a fault
+func (s *Server) AddFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, f)
+}
+
+// Remove all injected faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Submitted posts
+func (s *Server) Posts() []Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Post(nil), s.posts...)
+}
+
+// Posted comments
+func (s *Server) Comments() []Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Comment(nil), s.comments...)
+}
+
+// Remove all posts, comments, tokens and faults
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.posts = nil
+	s.comments = nil
+	s.faults = nil
+	s.tokens = make(map[string]string)
+	s.refreshTokens = make(map[string]string)
+}
+
+// Take fault of given kind if one is active
+// Must be called with lock held
+func (s *Server) takeFault(kind string) (Fault, bool) {
+	for i, f := range s.faults {
+		if f.Kind != kind {
+			continue
+		}
+
+		if f.Count > 0 {
+			s.faults[i].Count--
+			if s.faults[i].Count == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+
+		return f, true
+	}
+
+	return Fault{}, false
+}
+
+// Must be called with lock held
+func (s *Server) newId() string {
+	s.nextId++
+	return fmt.Sprintf(`%x`, 100000+s.nextId)
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set(`Content-Type`, `application/json; charset=UTF-8`)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Reddit's error response for api_type=json calls
+func writeAPIErrors(w http.ResponseWriter, errs ...[]string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		`json`: map[string]interface{}{
+			`errors`: errs,
+		},
+	})
+}
+
+func writeServerError(w http.ResponseWriter, status int) {
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+
+	// Reddit's 5xx pages are HTML
+	w.Header().Set(`Content-Type`, `text/html; charset=UTF-8`)
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<html><body><h1>%d %s</h1>all of our servers are busy right now</body></html>", status, http.StatusText(status))
+}
+
+func writeUnauthorized(w http.ResponseWriter) {
+	writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+		`message`: `Unauthorized`,
+		`error`:   http.StatusUnauthorized,
+	})
+}
+
+// Wrap OAuth API handler with bearer token check and fault injection
+func (s *Server) oauth(next func(w http.ResponseWriter, req *http.Request, user string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+
+		if f, ok := s.takeFault(FAULT_SERVER_ERROR); ok {
+			s.mu.Unlock()
+			writeServerError(w, f.Status)
+			return
+		}
+
+		if _, ok := s.takeFault(FAULT_UNAUTHORIZED); ok {
+			s.mu.Unlock()
+			writeUnauthorized(w)
+			return
+		}
+
+		auth := strings.Fields(req.Header.Get(`Authorization`))
+		var user string
+		ok := len(auth) == 2 && strings.EqualFold(auth[0], `bearer`)
+		if ok {
+			user, ok = s.tokens[auth[1]]
+		}
+
+		s.mu.Unlock()
+
+		if !ok {
+			writeUnauthorized(w)
+			return
+		}
+
+		next(w, req, user)
+	}
+}
+
+func (s *Server) handleAccessToken(w http.ResponseWriter, req *http.Request) {
+	if req.Method != `POST` {
+		http.Error(w, `method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.takeFault(FAULT_SERVER_ERROR); ok {
+		writeServerError(w, f.Status)
+		return
+	}
+
+	id, secret, ok := req.BasicAuth()
+	if !ok || (s.ClientId != `` && id != s.ClientId) || (s.Secret != `` && secret != s.Secret) {
+		writeUnauthorized(w)
+		return
+	}
+
+	var user string
+	var refreshToken string
+
+	switch req.PostFormValue(`grant_type`) {
+	case `password`:
+		user = req.PostFormValue(`username`)
+		if (s.Username != `` && user != s.Username) || (s.Password != `` && req.PostFormValue(`password`) != s.Password) {
+			writeJSON(w, http.StatusOK, map[string]string{`error`: `invalid_grant`})
+			return
+		}
+	case `refresh_token`:
+		user, ok = s.refreshTokens[req.PostFormValue(`refresh_token`)]
+		if !ok {
+			writeJSON(w, http.StatusOK, map[string]string{`error`: `invalid_grant`})
+			return
+		}
+	case `authorization_code`:
+		if req.PostFormValue(`code`) == `` {
+			writeJSON(w, http.StatusOK, map[string]string{`error`: `invalid_grant`})
+			return
+		}
+
+		user = s.Username
+		refreshToken = randomToken()
+		s.refreshTokens[refreshToken] = user
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{`error`: `unsupported_grant_type`})
+		return
+	}
+
+	token := randomToken()
+	s.tokens[token] = user
+
+	resp := map[string]interface{}{
+		`access_token`: token,
+		`token_type`:   `bearer`,
+		`expires_in`:   3600,
+		`scope`:        `*`,
+	}
+
+	if refreshToken != `` {
+		resp[`refresh_token`] = refreshToken
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Authorization page redirects straight back with a code
+func (s *Server) handleAuthorize(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	redirect := q.Get(`redirect_uri`)
+	if redirect == `` {
+		http.Error(w, `missing redirect_uri`, http.StatusBadRequest)
+		return
+	}
+
+	sep := `?`
+	if strings.Contains(redirect, `?`) {
+		sep = `&`
+	}
+
+	http.Redirect(w, req, redirect+sep+`state=`+q.Get(`state`)+`&code=`+randomToken(), http.StatusFound)
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, req *http.Request, user string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		`name`:               user,
+		`link_karma`:         1,
+		`comment_karma`:      1,
+		`created_utc`:        float64(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		`is_suspended`:       false,
+		`has_verified_email`: true,
+	})
+}
+
+// Descriptions of scopes given in comma separated scopes parameter, all if empty
+func (s *Server) handleScopes(w http.ResponseWriter, req *http.Request, user string) {
+	all := map[string]string{
+		`identity`:     `Access my reddit username and signup date.`,
+		`submit`:       `Submit links and comments from my account.`,
+		`read`:         `Access posts and comments through my account.`,
+		`modposts`:     `Approve, remove, mark nsfw, and distinguish content in subreddits I moderate.`,
+		`flair`:        `Select my subreddit flair. Change link flair on my submissions.`,
+		`edit`:         `Edit and delete my comments and submissions.`,
+		`mysubreddits`: `Access the list of subreddits I moderate, contribute to, and subscribe to.`,
+	}
+
+	names := strings.Split(req.URL.Query().Get(`scopes`), `,`)
+	if req.URL.Query().Get(`scopes`) == `` {
+		names = nil
+		for name := range all {
+			names = append(names, name)
+		}
+	}
+
+	scopes := make(map[string]interface{})
+
+	for _, name := range names {
+		desc, ok := all[name]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{`error`: 400, `message`: `invalid scope: ` + name})
+			return
+		}
+
+		scopes[name] = map[string]string{
+			`id`:          name,
+			`name`:        name,
+			`description`: desc,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, scopes)
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, req *http.Request, user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.takeFault(FAULT_RATELIMIT); ok {
+		writeAPIErrors(w, []string{`RATELIMIT`, `you are doing that too much. try again in 9 minutes.`, `ratelimit`})
+		return
+	}
+
+	if _, ok := s.takeFault(FAULT_ALREADY_SUB); ok {
+		writeAPIErrors(w, []string{`ALREADY_SUB`, `that link has already been submitted`, `url`})
+		return
+	}
+
+	p := Post{
+		Kind:      req.PostFormValue(`kind`),
+		SubReddit: req.PostFormValue(`sr`),
+		Title:     req.PostFormValue(`title`),
+		FlairId:   req.PostFormValue(`flair_id`),
+		FlairText: req.PostFormValue(`flair_text`),
+		Author:    user,
+		Created:   time.Now(),
+	}
+
+	if p.SubReddit == `` {
+		writeAPIErrors(w, []string{`SUBREDDIT_REQUIRED`, `you must specify a subreddit`, `sr`})
+		return
+	}
+
+	if p.Title == `` {
+		writeAPIErrors(w, []string{`NO_TEXT`, `we need something here`, `title`})
+		return
+	}
+
+	if len([]rune(p.Title)) > 300 {
+		writeAPIErrors(w, []string{`TOO_LONG`, `this is too long (max: 300)`, `title`})
+		return
+	}
+
+	switch p.Kind {
+	case `link`:
+		p.Url = req.PostFormValue(`url`)
+		if p.Url == `` {
+			writeAPIErrors(w, []string{`NO_URL`, `a url is required`, `url`})
+			return
+		}
+
+		if req.PostFormValue(`resubmit`) != `true` {
+			for _, existing := range s.posts {
+				if existing.Url == p.Url && strings.EqualFold(existing.SubReddit, p.SubReddit) {
+					writeAPIErrors(w, []string{`ALREADY_SUB`, `that link has already been submitted`, `url`})
+					return
+				}
+			}
+		}
+	case `self`:
+		p.Text = req.PostFormValue(`text`)
+	case `crosspost`:
+		p.Crosspost = req.PostFormValue(`crosspost_fullname`)
+
+		found := false
+		for _, existing := range s.posts {
+			if existing.Name == p.Crosspost {
+				found = true
+				p.Url = existing.Url
+				break
+			}
+		}
+
+		if !found {
+			writeAPIErrors(w, []string{`INVALID_CROSSPOST_THING`, `that isn't a valid post`, `crosspost_fullname`})
+			return
+		}
+	default:
+		writeAPIErrors(w, []string{`INVALID_OPTION`, `that option is not valid`, `kind`})
+		return
+	}
+
+	p.Id = s.newId()
+	p.Name = `t3_` + p.Id
+
+	s.posts = append(s.posts, p)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		`json`: map[string]interface{}{
+			`errors`: []interface{}{},
+			`data`: map[string]interface{}{
+				`url`:          fmt.Sprintf(`https://www.reddit.com/r/%v/comments/%v/`, p.SubReddit, p.Id),
+				`id`:           p.Id,
+				`name`:         p.Name,
+				`drafts_count`: 0,
+			},
+		},
+	})
+}
+
+func (s *Server) handleComment(w http.ResponseWriter, req *http.Request, user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.takeFault(FAULT_RATELIMIT); ok {
+		writeAPIErrors(w, []string{`RATELIMIT`, `you are doing that too much. try again in 9 minutes.`, `ratelimit`})
+		return
+	}
+
+	parent := req.PostFormValue(`thing_id`)
+	if !s.exists(parent) {
+		writeAPIErrors(w, []string{`NO_THING_ID`, `can't find that`, `parent`})
+		return
+	}
+
+	c := Comment{
+		Parent: parent,
+		Body:   req.PostFormValue(`text`),
+		Author: user,
+	}
+
+	if c.Body == `` {
+		writeAPIErrors(w, []string{`NO_TEXT`, `we need something here`, `text`})
+		return
+	}
+
+	c.Id = s.newId()
+	c.Name = `t1_` + c.Id
+
+	s.comments = append(s.comments, c)
+
+	writeCommentThing(w, c)
+}
+
+func (s *Server) handleDistinguish(w http.ResponseWriter, req *http.Request, user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := req.PostFormValue(`id`)
+
+	for i, c := range s.comments {
+		if c.Name != name {
+			continue
+		}
+
+		s.comments[i].Distinguished = req.PostFormValue(`how`) == `yes`
+		s.comments[i].Stickied = req.PostFormValue(`sticky`) == `true`
+
+		writeCommentThing(w, s.comments[i])
+		return
+	}
+
+	writeAPIErrors(w, []string{`NO_THING_ID`, `can't find that`, `id`})
+}
+
+func writeCommentThing(w http.ResponseWriter, c Comment) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		`json`: map[string]interface{}{
+			`errors`: []interface{}{},
+			`data`: map[string]interface{}{
+				`things`: []interface{}{
+					map[string]interface{}{
+						`kind`: `t1`,
+						`data`: map[string]interface{}{
+							`id`:        c.Id,
+							`name`:      c.Name,
+							`parent_id`: c.Parent,
+							`body`:      c.Body,
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+// Must be called with lock held
+func (s *Server) exists(name string) bool {
+	for _, p := range s.posts {
+		if p.Name == name {
+			return true
+		}
+	}
+
+	for _, c := range s.comments {
+		if c.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Listing of posts by url= or id= (comma separated fullnames)
+func (s *Server) handleInfo(w http.ResponseWriter, req *http.Request, user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := req.URL.Query()
+	ids := make(map[string]bool)
+	for _, id := range strings.Split(q.Get(`id`), `,`) {
+		if id != `` {
+			ids[id] = true
+		}
+	}
+
+	var posts []Post
+
+	for i := len(s.posts) - 1; i >= 0; i-- {
+		p := s.posts[i]
+
+		if q.Get(`url`) != `` && p.Url != q.Get(`url`) {
+			continue
+		}
+
+		if len(ids) > 0 && !ids[p.Name] {
+			continue
+		}
+
+		posts = append(posts, p)
+	}
+
+	writeListing(w, posts)
+}
+
+// /user/{name}/submitted, newest first
+func (s *Server) handleUser(w http.ResponseWriter, req *http.Request, user string) {
+	parts := strings.Split(strings.Trim(req.URL.Path, `/`), `/`)
+	if len(parts) != 3 || parts[2] != `submitted` {
+		http.NotFound(w, req)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var posts []Post
+
+	for i := len(s.posts) - 1; i >= 0; i-- {
+		if strings.EqualFold(s.posts[i].Author, parts[1]) {
+			posts = append(posts, s.posts[i])
+		}
+	}
+
+	writeListing(w, posts)
+}
+
+func writeListing(w http.ResponseWriter, posts []Post) {
+	children := []interface{}{}
+
+	for _, p := range posts {
+		children = append(children, map[string]interface{}{
+			`kind`: `t3`,
+			`data`: map[string]interface{}{
+				`id`:          p.Id,
+				`name`:        p.Name,
+				`subreddit`:   p.SubReddit,
+				`title`:       p.Title,
+				`url`:         p.Url,
+				`permalink`:   fmt.Sprintf(`/r/%v/comments/%v/`, p.SubReddit, p.Id),
+				`created_utc`: float64(p.Created.Unix()),
+				`author`:      p.Author,
+			},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		`kind`: `Listing`,
+		`data`: map[string]interface{}{
+			`children`: children,
+		},
+	})
+}
+
+// /r/{sub}/api/link_flair_v2 and /r/{sub}/about
+func (s *Server) handleSubreddit(w http.ResponseWriter, req *http.Request, user string) {
+	parts := strings.Split(strings.Trim(req.URL.Path, `/`), `/`)
+	if len(parts) < 3 {
+		http.NotFound(w, req)
+		return
+	}
+
+	sub := strings.ToLower(parts[1])
+	endpoint := strings.Join(parts[2:], `/`)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch endpoint {
+	case `api/link_flair_v2`, `api/link_flair`:
+		flairs := s.Flairs[sub]
+		if flairs == nil {
+			flairs = []Flair{}
+		}
+
+		writeJSON(w, http.StatusOK, flairs)
+	case `about`:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			`kind`: `t5`,
+			`data`: map[string]interface{}{
+				`display_name`:        parts[1],
+				`submission_type`:     `any`,
+				`subreddit_type`:      `public`,
+				`user_is_banned`:      false,
+				`user_is_contributor`: false,
+				`user_is_moderator`:   true,
+				`link_flair_enabled`:  len(s.Flairs[sub]) > 0,
+			},
+		})
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// POST adds a fault (form: kind, status, count), DELETE clears faults, GET lists them
+func (s *Server) handleFaults(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case `POST`:
+		f := Fault{Kind: req.PostFormValue(`kind`)}
+
+		switch f.Kind {
+		case FAULT_ALREADY_SUB, FAULT_RATELIMIT, FAULT_UNAUTHORIZED, FAULT_SERVER_ERROR:
+		default:
+			http.Error(w, `unknown fault kind`, http.StatusBadRequest)
+			return
+		}
+
+		_, _ = fmt.Sscanf(req.PostFormValue(`status`), `%d`, &f.Status)
+		_, _ = fmt.Sscanf(req.PostFormValue(`count`), `%d`, &f.Count)
+
+		s.AddFault(f)
+	case `DELETE`:
+		s.ClearFaults()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	faults := s.faults
+	if faults == nil {
+		faults = []Fault{}
+	}
+
+	writeJSON(w, http.StatusOK, faults)
+}
+
+// Dump posts and comments
+func (s *Server) handleState(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		`posts`:    s.Posts(),
+		`comments`: s.Comments(),
+	})
+}
+
+func (s *Server) handleReset(w http.ResponseWriter, req *http.Request) {
+	if req.Method != `POST` {
+		http.Error(w, `method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,83 +1,406 @@
 package main
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"fmt"
+	"github.com/mmcdole/gofeed"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	KIND_LINK = `link` // Link post (default)
+	KIND_SELF = `self` // Text post
+
+	KIND_CROSSPOST = `crosspost` // Crosspost of already submitted post, only used internally
+
+	DEFAULT_FEED_INTERVAL = time.Hour   // How often feed is polled in daemon mode
+	MIN_FEED_INTERVAL     = time.Minute // Shortest allowed poll interval
+
+	MAX_FLAIR_LENGTH = 64 // Reddit's limit for flair text
+)
+
+var (
+	// Reddit subreddit names, some old subreddits have only 2 characters
+	subredditNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_]{1,20}$`)
+
+	// Flair template IDs are UUIDs
+	flairIdPattern = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
 )
 
 type FeedConfig struct {
-	Subreddit string `json:"subreddit"`
-	Feeds     []struct {
-		Subreddit  string `json:"subreddit,omitempty"`
-		Title      string `json:"title,omitempty"`
-		Prefix     string `json:"prefix,omitempty"`
-		Suffix     string `json:"suffix,omitempty"`
-		FlairId    string `json:"fid,omitempty"`
-		Flair      string `json:"flair,omitempty"`
-		UrlAddress string `json:"url"`
-	} `json:"feeds"`
+	Subreddit  string                     `json:"subreddit"`
+	DupCheck   string                     `json:"dupcheck,omitempty"`   // Default duplicate check window
+	Subreddits map[string]SubredditLimits `json:"subreddits,omitempty"` // Posting limits per subreddit, "*" for all others
+	Feeds      []FeedSource               `json:"feeds"`
+
+	NewFeeds       string `json:"new_feeds,omitempty"`        // Items of new feeds and feeds with changed URL: all (default), seed or newest
+	NewFeedsNewest int    `json:"new_feeds_newest,omitempty"` // Items submitted with newest policy (default 1)
+
+	Include []string `json:"include,omitempty"` // Glob patterns of files with more feeds and subreddit limits, for example feeds.d/*.json
+
+	files []feedConfigFile // Files configuration was merged from, first is the top-level file
+}
+
+// Single feed in feed configuration
+type FeedSource struct {
+	Subreddit  string   `json:"subreddit,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	Prefix     string   `json:"prefix,omitempty"`
+	Suffix     string   `json:"suffix,omitempty"`
+	FlairId    string   `json:"fid,omitempty"`
+	Flair      string   `json:"flair,omitempty"`
+	UrlAddress string   `json:"url"`
+	Kind       string   `json:"kind,omitempty"`      // link (default) or self
+	Template   string   `json:"template,omitempty"`  // Text post body template (self posts only)
+	MaxLength  int      `json:"maxlen,omitempty"`    // Max length of summary in text post body or comment
+	Comment    string   `json:"comment,omitempty"`   // First comment template, no comment if empty
+	Sticky     bool     `json:"sticky,omitempty"`    // Distinguish and sticky the first comment (bot must be moderator)
+	Crosspost  []string `json:"crosspost,omitempty"` // Crosspost targets, the post is submitted to subreddit first
+	DupCheck   string   `json:"dupcheck,omitempty"`  // Skip URLs already submitted to subreddit within this window (for example 720h), 0 disables
+
+	Windows PostingWindows `json:"windows,omitempty"` // When this feed's links are posted, in addition to subreddit's windows
+
+	Interval string `json:"interval,omitempty"` // Poll interval in daemon mode, for example 30m (default 1h)
+
+	source string // File and index of included feed for error messages
+}
+
+// Poll interval of feed in daemon mode
+func (feed FeedSource) PollInterval() time.Duration {
+	return parseDurationDefault(feed.Interval, DEFAULT_FEED_INTERVAL)
 }
 
-func LoadFeedConfig(fname string) FeedConfig {
+// Duplicate check window for feed, uses default if feed doesn't have one
+func (c *FeedConfig) DupCheckWindow(feed FeedSource) (time.Duration, error) {
+	window := feed.DupCheck
+	if window == `` {
+		window = c.DupCheck
+	}
+
+	if window == `` {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return 0, err
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf(`negative duration %v`, window)
+	}
+
+	return d, nil
+}
+
+// Download and parse feed, transient errors are retried according to retry policy
+func FetchFeed(ctx context.Context, client *http.Client, feedUrl string, retry RetryPolicy) (*gofeed.Feed, error) {
+	req, err := http.NewRequestWithContext(ctx, `GET`, feedUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set(`User-Agent`, USER_AGENT)
+
+	resp, body, err := retry.Do(client, req, log.Printf)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf(`HTTP status %v`, resp.StatusCode)
+	}
+
+	return gofeed.NewParser().Parse(bytes.NewReader(body))
+}
+
+// Load feed configuration, JSON, YAML or TOML by extension
+// Unknown fields and invalid values are reported all at once with their positions
+func LoadFeedConfig(fname string) (cfg FeedConfig, err error) {
 	cfgdata, err := ioutil.ReadFile(fname)
 	if err != nil {
-		log.Fatalf(`couldn't open %v'`, fname)
+		return cfg, err
 	}
 
-	var cfg FeedConfig
+	return ParseFeedConfig(fname, cfgdata)
+}
 
-	err = json.Unmarshal(cfgdata, &cfg)
+// Decode and validate feed configuration, format is detected from fname's extension
+// Files matching include patterns are merged, errors name the file they're in
+func ParseFeedConfig(fname string, data []byte) (cfg FeedConfig, err error) {
+	err = parseFeedConfig(fname, data, &cfg, &cfg)
+	return cfg, err
+}
+
+// Load feed configuration from top-level file which also has bot configuration
+func LoadCombinedConfig(fname string) (cfg FeedConfig, err error) {
+	data, err := ioutil.ReadFile(fname)
 	if err != nil {
-		panic(err)
+		return cfg, err
 	}
 
-	return cfg
+	var combined combinedConfig
+
+	err = parseFeedConfig(fname, data, &combined, &combined.FeedConfig)
+	return combined.FeedConfig, err
 }
 
-func (c *FeedConfig) ValidateFeedConfig() (err error) {
+// Decode v strictly, merge included files to its feed configuration cfg and validate it
+func parseFeedConfig(fname string, data []byte, v interface{}, cfg *FeedConfig) error {
+	positions, errs, err := decodeConfig(fname, data, v)
+	if err != nil {
+		return err
+	}
+
+	cfg.files = []feedConfigFile{newFeedConfigFile(fname, data, positions, 0, cfg)}
+
+	includeErrs, err := cfg.include(fname)
+	if err != nil {
+		return err
+	}
+
+	errs = append(errs, includeErrs...)
+
+	for _, e := range cfg.locateErrors(cfg.ValidateFeedConfig()) {
+		// Value which couldn't be decoded is only reported once
+		if !errs.has(e.File, e.Path) {
+			errs = append(errs, e)
+		}
+	}
 
+	cfg.sortErrors(errs)
+
+	return errs.err()
+}
+
+// Check feed configuration, errors have JSON paths of invalid values
+func (c *FeedConfig) ValidateFeedConfig() (errs ConfigErrors) {
 	if c.Subreddit == `` {
-		return fmt.Errorf(`default subreddit name is empty`)
+		errs.add(`subreddit`, `default subreddit name is empty`)
+	} else if err := ValidateSubredditName(c.Subreddit); err != nil {
+		errs.add(`subreddit`, `%v`, err)
 	}
 
-	seenTitles := make(map[string]bool)
+	for name, limits := range c.Subreddits {
+		path := `subreddits.` + name
 
-	seenUrls := make(map[string]bool)
+		if name != SUBREDDIT_DEFAULT {
+			if err := ValidateSubredditName(name); err != nil {
+				errs.add(path, `%v`, err)
+			}
+		}
 
-	for _, feed := range c.Feeds {
-		if feed.UrlAddress == `` {
-			return fmt.Errorf(`empty URL address`)
+		err := limits.Validate()
+		if err != nil {
+			errs.add(path, `%v`, err)
 		}
+	}
+
+	switch c.NewFeeds {
+	case ``, NEW_FEEDS_ALL, NEW_FEEDS_SEED, NEW_FEEDS_NEWEST:
+	default:
+		errs.add(`new_feeds`, `invalid new_feeds %q, use %v, %v or %v`, c.NewFeeds, NEW_FEEDS_ALL, NEW_FEEDS_SEED, NEW_FEEDS_NEWEST)
+	}
 
-		// Check that URL scheme is valid
-		_, err := url.Parse(feed.UrlAddress)
+	if c.NewFeedsNewest < 0 {
+		errs.add(`new_feeds_newest`, `negative new_feeds_newest`)
+	}
+
+	if c.DupCheck != `` {
+		d, err := time.ParseDuration(c.DupCheck)
 		if err != nil {
-			return fmt.Errorf(`error: parsing URL %v - %v`, feed.UrlAddress, err)
+			errs.add(`dupcheck`, `invalid dupcheck: %v`, err)
+		} else if d < 0 {
+			errs.add(`dupcheck`, `negative dupcheck`)
+		}
+	}
+
+	// Index of feed by title and URL
+	seenTitles := make(map[string]int)
+
+	seenUrls := make(map[string]int)
+
+	for i, feed := range c.Feeds {
+		path := fmt.Sprintf(`feeds[%v]`, i)
+
+		if feed.UrlAddress == `` {
+			errs.add(path+`.url`, `empty URL address`)
+		} else if err := validateFeedUrl(feed.UrlAddress); err != nil {
+			errs.add(path+`.url`, `%v`, err)
 		}
 
 		if feed.Title == `` {
-			return fmt.Errorf(`empty title for %v`, feed.UrlAddress)
+			errs.add(path+`.title`, `empty title`)
+		}
+
+		if feed.Subreddit != `` {
+			if err := ValidateSubredditName(feed.Subreddit); err != nil {
+				errs.add(path+`.subreddit`, `%v`, err)
+			}
 		}
 
-		_, urlOk := seenUrls[feed.UrlAddress]
+		switch feed.Kind {
+		case ``, KIND_LINK:
+			if feed.Template != `` {
+				errs.add(path+`.template`, `template is only used with kind %v`, KIND_SELF)
+			}
+		case KIND_SELF:
+			_, err := NewSelfTextTemplate(feed.Template)
+			if err != nil {
+				errs.add(path+`.template`, `invalid template: %v`, err)
+			}
+		default:
+			errs.add(path+`.kind`, `invalid kind %q, use %v or %v`, feed.Kind, KIND_LINK, KIND_SELF)
+		}
+
+		if feed.Comment != `` {
+			_, err := ParseItemTemplate(`comment`, feed.Comment)
+			if err != nil {
+				errs.add(path+`.comment`, `invalid comment template: %v`, err)
+			}
+		} else if feed.Sticky {
+			errs.add(path+`.sticky`, `sticky set without comment template`)
+		}
+
+		if feed.Flair != `` && feed.FlairId == `` {
+			errs.add(path+`.flair`, `flair text needs flair template id in fid`)
+		}
+
+		if feed.FlairId != `` && !flairIdPattern.MatchString(feed.FlairId) {
+			errs.add(path+`.fid`, `invalid flair template id %q, expected UUID such as 6f2b7c3e-1a2b-11e8-9c4d-0e1a2b3c4d5e`, feed.FlairId)
+		}
 
-		if !urlOk {
-			seenUrls[feed.UrlAddress] = true
-		} else {
-			return fmt.Errorf(`URL address %v exists already`, feed.UrlAddress)
+		if utf8.RuneCountInString(feed.Flair) > MAX_FLAIR_LENGTH {
+			errs.add(path+`.flair`, `flair text is longer than %v characters`, MAX_FLAIR_LENGTH)
 		}
 
-		_, titleOk := seenTitles[feed.Title]
+		if feed.DupCheck != `` {
+			_, err := c.DupCheckWindow(feed)
+			if err != nil {
+				errs.add(path+`.dupcheck`, `invalid dupcheck: %v`, err)
+			}
+		}
 
-		if !titleOk {
-			seenTitles[feed.Title] = true
-		} else {
-			return fmt.Errorf(`title %v exists already ref: %v`, feed.Title, feed.UrlAddress)
+		for j, w := range feed.Windows {
+			err := w.Validate()
+			if err != nil {
+				errs.add(fmt.Sprintf(`%v.windows[%v]`, path, j), `%v`, err)
+			}
 		}
 
+		if len(feed.Windows) > 0 && feed.Windows.Validate() == nil {
+			subReddit := feed.Subreddit
+			if subReddit == `` {
+				subReddit = c.Subreddit
+			}
+
+			// Crossposts are posted in the feed's windows too
+			for _, target := range append([]string{subReddit}, feed.Crosspost...) {
+				subWindows := c.SubredditSettings(target).Windows
+
+				if target == `` || subWindows.Validate() != nil {
+					continue
+				}
+
+				if !windowsOverlap(feed.Windows, subWindows) {
+					errs.add(path+`.windows`, `windows never overlap with posting windows of %v, links would never be posted`, target)
+				}
+			}
+		}
+
+		seenTargets := make(map[string]bool)
+
+		for j, target := range feed.Crosspost {
+			targetPath := fmt.Sprintf(`%v.crosspost[%v]`, path, j)
+
+			if target == `` {
+				errs.add(targetPath, `empty crosspost subreddit`)
+				continue
+			}
+
+			if err := ValidateSubredditName(target); err != nil {
+				errs.add(targetPath, `%v`, err)
+			}
+
+			if strings.EqualFold(target, feed.Subreddit) || (feed.Subreddit == `` && strings.EqualFold(target, c.Subreddit)) {
+				errs.add(targetPath, `crosspost subreddit %v is the same as primary subreddit`, target)
+			}
+
+			if seenTargets[strings.ToLower(target)] {
+				errs.add(targetPath, `crosspost subreddit %v listed twice`, target)
+			}
+
+			seenTargets[strings.ToLower(target)] = true
+		}
+
+		if feed.Interval != `` {
+			d, err := time.ParseDuration(feed.Interval)
+			if err != nil {
+				errs.add(path+`.interval`, `invalid interval: %v`, err)
+			} else if d < MIN_FEED_INTERVAL {
+				errs.add(path+`.interval`, `interval is shorter than %v`, MIN_FEED_INTERVAL)
+			}
+		}
+
+		if feed.MaxLength < 0 {
+			errs.add(path+`.maxlen`, `negative maxlen`)
+		}
+
+		if feed.UrlAddress != `` {
+			if j, ok := seenUrls[feed.UrlAddress]; ok {
+				errs.add(path+`.url`, `URL address %v exists already in %v`, feed.UrlAddress, c.feedRef(j))
+			} else {
+				seenUrls[feed.UrlAddress] = i
+			}
+		}
+
+		if feed.Title != `` {
+			if j, ok := seenTitles[feed.Title]; ok {
+				errs.add(path+`.title`, `title %v exists already in %v`, feed.Title, c.feedRef(j))
+			} else {
+				seenTitles[feed.Title] = i
+			}
+		}
+	}
+
+	return errs
+}
+
+// Check that feed URL is an absolute http(s) URL
+func validateFeedUrl(address string) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf(`invalid URL: %v`, err)
+	}
+
+	if u.Scheme != `http` && u.Scheme != `https` {
+		return fmt.Errorf(`URL %v must use http or https`, address)
+	}
+
+	if u.Hostname() == `` {
+		return fmt.Errorf(`URL %v has no host`, address)
+	}
+
+	return nil
+}
+
+// Check subreddit name against Reddit's naming rules
+func ValidateSubredditName(name string) error {
+	lower := strings.ToLower(name)
+	if strings.HasPrefix(lower, `r/`) || strings.HasPrefix(lower, `/r/`) {
+		return fmt.Errorf(`subreddit %q must be given without r/ prefix`, name)
+	}
+
+	if !subredditNamePattern.MatchString(name) {
+		return fmt.Errorf(`invalid subreddit name %q, names are 2-21 letters, digits or underscores and don't start with underscore`, name)
 	}
 
 	return nil
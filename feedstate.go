@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	FEED_STATE_FILE = `feeds.state.json` // Feed fingerprints file name in state directory
+
+	// What to do with items of a feed that is new or has a new URL
+	NEW_FEEDS_ALL    = `all`    // Submit all items (default)
+	NEW_FEEDS_SEED   = `seed`   // Add items to submitted cache without submitting
+	NEW_FEEDS_NEWEST = `newest` // Submit newest items, add the rest to submitted cache
+
+	DEFAULT_NEW_FEEDS_NEWEST = 1 // Items submitted with newest policy
+)
+
+// Feed configuration entry as seen on earlier run
+type FeedFingerprint struct {
+	Url  string    `json:"url"`
+	Hash string    `json:"hash"` // SHA-256 of feed's configuration
+	Seen time.Time `json:"seen"` // When feed was first fetched with this URL
+}
+
+// Feeds seen on earlier runs by feed title
+type FeedState struct {
+	Feeds map[string]FeedFingerprint `json:"feeds"`
+
+	fname string
+}
+
+// Fingerprint of feed configuration entry
+func feedHash(feed FeedSource) string {
+	data, _ := json.Marshal(feed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Load feed state file
+// Returns empty state if file doesn't exist
+func LoadFeedState(fname string) (*FeedState, error) {
+	s := &FeedState{
+		Feeds: make(map[string]FeedFingerprint),
+		fname: fname,
+	}
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		return nil, fmt.Errorf(`%v: %v`, fname, err)
+	}
+
+	if s.Feeds == nil {
+		s.Feeds = make(map[string]FeedFingerprint)
+	}
+
+	return s, nil
+}
+
+func (s *FeedState) Save() error {
+	data, err := json.MarshalIndent(s, ``, `  `)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.fname, data)
+}
+
+// Log differences between feed configuration and earlier runs
+// Removed feeds are forgotten and changed feeds updated, new feeds and feeds with a new URL are marked seen after first fetch
+func (s *FeedState) Sync(feeds []FeedSource) error {
+	current := make(map[string]bool)
+
+	for _, feed := range feeds {
+		current[feed.Title] = true
+
+		old, ok := s.Feeds[feed.Title]
+
+		switch {
+		case !ok:
+			log.Printf(`Feed '%v' added`, feed.Title)
+		case old.Url != feed.UrlAddress:
+			log.Printf(`Feed '%v' URL changed from %v to %v`, feed.Title, old.Url, feed.UrlAddress)
+		case old.Hash != feedHash(feed):
+			log.Printf(`Feed '%v' changed`, feed.Title)
+			old.Hash = feedHash(feed)
+			s.Feeds[feed.Title] = old
+		}
+	}
+
+	var removed []string
+	for title := range s.Feeds {
+		if !current[title] {
+			removed = append(removed, title)
+		}
+	}
+
+	sort.Strings(removed)
+
+	for _, title := range removed {
+		log.Printf(`Feed '%v' removed`, title)
+		delete(s.Feeds, title)
+	}
+
+	return s.Save()
+}
+
+// Is feed new or has its URL changed since it was last fetched
+func (s *FeedState) IsNew(feed FeedSource) bool {
+	old, ok := s.Feeds[feed.Title]
+	return !ok || old.Url != feed.UrlAddress
+}
+
+// Remember feed after its items were handled
+func (s *FeedState) Seen(feed FeedSource) error {
+	s.Feeds[feed.Title] = FeedFingerprint{
+		Url:  feed.UrlAddress,
+		Hash: feedHash(feed),
+		Seen: time.Now(),
+	}
+
+	return s.Save()
+}
+
+// Split links of a new feed by new feed policy to links that are submitted and links that are only added to cache
+func (c *FeedConfig) splitNewFeedLinks(links []SubmitLink) (submit []SubmitLink, seed []SubmitLink) {
+	keep := len(links)
+
+	switch c.NewFeeds {
+	case NEW_FEEDS_SEED:
+		keep = 0
+	case NEW_FEEDS_NEWEST:
+		keep = c.NewFeedsNewest
+		if keep == 0 {
+			keep = DEFAULT_NEW_FEEDS_NEWEST
+		}
+	}
+
+	if keep >= len(links) {
+		return links, nil
+	}
+
+	sorted := make([]SubmitLink, len(links))
+	copy(sorted, links)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Published.After(sorted[j].Published)
+	})
+
+	return sorted[:keep], sorted[keep:]
+}
+
+// Apply new feed policy to links of a new feed
+// Returns links that should be submitted, the rest are added to submitted cache
+func (c *FeedConfig) newFeedLinks(feed FeedSource, links []SubmitLink) ([]SubmitLink, error) {
+	submit, seed := c.splitNewFeedLinks(links)
+	if len(seed) == 0 {
+		return submit, nil
+	}
+
+	log.Printf(`Feed '%v' is new, submitting %v of %v items and adding the rest to cache`, feed.Title, len(submit), len(links))
+
+	err := seedLinks(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	return submit, nil
+}
+
+// Add links to their subreddit's submitted cache without submitting
+func seedLinks(links []SubmitLink) error {
+	bySub := make(map[string][]SubmitLink)
+	for _, link := range links {
+		bySub[link.SubReddit] = append(bySub[link.SubReddit], link)
+	}
+
+	for subReddit, subLinks := range bySub {
+		submitFile := cacheFile(subReddit)
+
+		submitted, err := LoadSubmitted(submitFile)
+		if err != nil {
+			return err
+		}
+
+		for _, link := range subLinks {
+			submitted[link.Url] = link.Published
+		}
+
+		log.Printf(`Saving submitted cache..`)
+
+		err = SaveSubmitted(submitFile, submitted)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
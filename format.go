@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Configuration file formats, detected by file extension
+const (
+	FORMAT_JSON = `json`
+	FORMAT_YAML = `yaml`
+	FORMAT_TOML = `toml`
+)
+
+// Format of configuration file by its extension, JSON if extension is unknown
+func configFormat(fname string) string {
+	switch strings.ToLower(filepath.Ext(fname)) {
+	case `.yaml`, `.yml`:
+		return FORMAT_YAML
+	case `.toml`:
+		return FORMAT_TOML
+	}
+
+	return FORMAT_JSON
+}
+
+// Convert YAML or TOML configuration to JSON so that it's decoded and validated with the same code as JSON files
+// JSON is returned as is
+func configToJSON(fname string, data []byte) ([]byte, error) {
+	var v interface{}
+
+	switch configFormat(fname) {
+	case FORMAT_YAML:
+		err := yaml.Unmarshal(data, &v)
+		if err != nil {
+			return nil, fmt.Errorf(`%v: %v`, fname, err)
+		}
+
+		v = jsonCompatible(v)
+	case FORMAT_TOML:
+		var m map[string]interface{}
+
+		_, err := toml.Decode(string(data), &m)
+		if err != nil {
+			return nil, fmt.Errorf(`%v: %v`, fname, err)
+		}
+
+		v = m
+	default:
+		return data, nil
+	}
+
+	if v == nil {
+		// Empty YAML document
+		v = map[string]interface{}{}
+	}
+
+	return json.Marshal(v)
+}
+
+// YAML maps have interface{} keys which JSON can't encode
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprintf(`%v`, k)] = jsonCompatible(val)
+		}
+
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonCompatible(v[i])
+		}
+	}
+
+	return v
+}
+
+// Decode configuration file strictly by its format
+// Positions are only available for JSON files, errors in YAML and TOML files have JSON paths without line and column
+func decodeConfig(fname string, data []byte, v interface{}) (positions jsonPositions, errs ConfigErrors, err error) {
+	if configFormat(fname) == FORMAT_JSON {
+		return decodeStrictJSON(fname, data, v)
+	}
+
+	jsonData, err := configToJSON(fname, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, errs, err = decodeStrictJSON(fname, jsonData, v)
+
+	// Offsets point to converted JSON
+	for i := range errs {
+		errs[i].Line = 0
+		errs[i].Column = 0
+		errs[i].offset = -1
+	}
+
+	return nil, errs, err
+}
+
+// Encode configuration struct in given format, keys are in struct field order except in TOML
+func encodeConfig(v interface{}, format string) ([]byte, error) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// Structs such as unset retry policy can't be omitted with omitempty
+	jsonData, err = omitEmptyObjects(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FORMAT_JSON:
+		var buf bytes.Buffer
+
+		err = json.Indent(&buf, jsonData, ``, `  `)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString("\n")
+
+		return buf.Bytes(), nil
+	case FORMAT_YAML:
+		// JSON is valid YAML, MapSlice keeps key order
+		var ms yaml.MapSlice
+
+		err = yaml.Unmarshal(jsonData, &ms)
+		if err != nil {
+			return nil, err
+		}
+
+		return yaml.Marshal(ms)
+	case FORMAT_TOML:
+		var m map[string]interface{}
+
+		dec := json.NewDecoder(bytes.NewReader(jsonData))
+		dec.UseNumber()
+
+		err = dec.Decode(&m)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+
+		err = toml.NewEncoder(&buf).Encode(tomlCompatible(m))
+		if err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf(`unknown format %q, use %v, %v or %v`, format, FORMAT_JSON, FORMAT_YAML, FORMAT_TOML)
+}
+
+// Remove empty objects from JSON object values, key order is kept
+func omitEmptyObjects(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+
+	empty, err := copyNonEmpty(dec, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if empty {
+		return []byte(`{}`), nil
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Copy next JSON value from dec to buf without empty objects
+// Returns true without writing anything if the value itself is an empty object
+func copyNonEmpty(dec *json.Decoder, buf *bytes.Buffer) (empty bool, err error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		var obj bytes.Buffer
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return false, err
+			}
+
+			var val bytes.Buffer
+
+			empty, err := copyNonEmpty(dec, &val)
+			if err != nil {
+				return false, err
+			}
+
+			if empty {
+				continue
+			}
+
+			if obj.Len() > 0 {
+				obj.WriteByte(',')
+			}
+
+			keyData, err := json.Marshal(key)
+			if err != nil {
+				return false, err
+			}
+
+			obj.Write(keyData)
+			obj.WriteByte(':')
+			obj.Write(val.Bytes())
+		}
+
+		_, err = dec.Token() // }
+		if err != nil {
+			return false, err
+		}
+
+		if obj.Len() == 0 {
+			return true, nil
+		}
+
+		buf.WriteByte('{')
+		buf.Write(obj.Bytes())
+		buf.WriteByte('}')
+	case json.Delim('['):
+		buf.WriteByte('[')
+
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			empty, err := copyNonEmpty(dec, buf)
+			if err != nil {
+				return false, err
+			}
+
+			if empty {
+				// Array elements are kept
+				buf.WriteString(`{}`)
+			}
+		}
+
+		_, err = dec.Token() // ]
+		if err != nil {
+			return false, err
+		}
+
+		buf.WriteByte(']')
+	default:
+		data, err := json.Marshal(tok)
+		if err != nil {
+			return false, err
+		}
+
+		buf.Write(data)
+	}
+
+	return false, nil
+}
+
+// JSON numbers as TOML integers or floats
+func tomlCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = tomlCompatible(val)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = tomlCompatible(v[i])
+		}
+	}
+
+	return v
+}
+
+// Decode feed configuration, bot configuration or both in one file for converting, included files are not loaded
+// Unknown fields are errors so that nothing is lost in conversion
+func decodeAnyConfig(fname string, data []byte) (interface{}, error) {
+	jsonData, err := configToJSON(fname, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys map[string]json.RawMessage
+
+	err = json.Unmarshal(jsonData, &keys)
+	if err != nil {
+		return nil, fmt.Errorf(`%v: %v`, fname, err)
+	}
+
+	feedKeys := false
+	configKeys := false
+
+	for key := range keys {
+		if _, ok := jsonFieldType(reflect.TypeOf(FeedConfig{}), key); ok {
+			feedKeys = true
+		} else {
+			configKeys = true
+		}
+	}
+
+	var v interface{}
+
+	switch {
+	case feedKeys && configKeys:
+		v = &combinedConfig{}
+	case feedKeys:
+		v = &FeedConfig{}
+	default:
+		v = &Configuration{}
+	}
+
+	_, errs, err := decodeConfig(fname, data, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, errs.err()
+}
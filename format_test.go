@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeConfigOmitsEmptyStructs(t *testing.T) {
+	cfg := Configuration{Username: `u`}
+
+	for _, format := range []string{FORMAT_JSON, FORMAT_YAML, FORMAT_TOML} {
+		data, err := encodeConfig(cfg, format)
+		if err != nil {
+			t.Fatalf(`%v: %v`, format, err)
+		}
+
+		if strings.Contains(string(data), `retry`) {
+			t.Errorf(`%v: empty retry policy written: %s`, format, data)
+		}
+	}
+
+	cfg.Retry.Attempts = 5
+
+	data, err := encodeConfig(cfg, FORMAT_YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), "retry:\n  attempts: 5\n") {
+		t.Errorf(`retry policy missing: %s`, data)
+	}
+}
@@ -1,13 +1,15 @@
 module github.com/raspi/SimpleRedditRSSBot
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/PuerkitoBio/goquery v1.4.1 // indirect
 	github.com/andybalholm/cascadia v1.0.0 // indirect
 	github.com/mmcdole/gofeed v1.0.0-beta2
 	github.com/mmcdole/goxpp v0.0.0-20170720115402-77e4a51a73ed // indirect
 	github.com/stretchr/testify v1.4.0 // indirect
-	golang.org/x/net v0.0.0-20180906233101-161cd47e91fd // indirect
+	golang.org/x/net v0.0.0-20180906233101-161cd47e91fd
 	golang.org/x/text v0.3.0 // indirect
+	gopkg.in/yaml.v2 v2.2.8
 )
 
 go 1.13
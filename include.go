@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Top-level file with both bot and feed configuration
+type combinedConfig struct {
+	Configuration
+	FeedConfig
+}
+
+// File which is part of merged feed configuration
+type feedConfigFile struct {
+	Name  string
+	Feeds int // Number of feeds in file
+
+	first      int             // Index of file's first feed in merged feeds
+	subreddits map[string]bool // Subreddit limit keys set in file
+	data       []byte
+	positions  jsonPositions
+}
+
+func newFeedConfigFile(fname string, data []byte, positions jsonPositions, first int, part *FeedConfig) feedConfigFile {
+	f := feedConfigFile{
+		Name:       fname,
+		Feeds:      len(part.Feeds),
+		first:      first,
+		subreddits: make(map[string]bool),
+		data:       data,
+		positions:  positions,
+	}
+
+	for name := range part.Subreddits {
+		f.subreddits[name] = true
+	}
+
+	return f
+}
+
+// Check if configuration file has feed configuration, so that it's used instead of separate feed file
+func hasFeedConfig(fname string) (bool, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	data, err = configToJSON(fname, data)
+	if err != nil {
+		return false, err
+	}
+
+	var keys map[string]json.RawMessage
+
+	err = json.Unmarshal(data, &keys)
+	if err != nil {
+		return false, fmt.Errorf(`%v: %v`, fname, err)
+	}
+
+	for key := range keys {
+		if _, ok := jsonFieldType(reflect.TypeOf(FeedConfig{}), key); ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Load files matching include patterns and merge their feeds and subreddit limits
+// Patterns are relative to directory of fname, errors in included files are returned in errs
+func (c *FeedConfig) include(fname string) (errs ConfigErrors, err error) {
+	included := map[string]bool{
+		filepath.Clean(fname): true,
+	}
+
+	for _, pattern := range c.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(fname), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return errs, fmt.Errorf(`%v: invalid include pattern %q: %v`, fname, pattern, err)
+		}
+
+		if len(matches) == 0 {
+			log.Printf(`warning: include %q doesn't match any files`, pattern)
+		}
+
+		for _, match := range matches {
+			if included[filepath.Clean(match)] {
+				continue
+			}
+
+			included[filepath.Clean(match)] = true
+
+			fileErrs, err := c.includeFile(match)
+			if err != nil {
+				return errs, err
+			}
+
+			errs = append(errs, fileErrs...)
+		}
+	}
+
+	if len(c.files) > 1 {
+		// Duplicate errors must tell in which file the other feed is
+		for i := 0; i < c.files[0].Feeds; i++ {
+			c.Feeds[i].source = fmt.Sprintf(`%v feeds[%v]`, fname, i)
+		}
+	}
+
+	return errs, nil
+}
+
+// Merge included file, only feeds and subreddit limits can be set in it
+func (c *FeedConfig) includeFile(fname string) (errs ConfigErrors, err error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	var part FeedConfig
+
+	positions, errs, err := decodeConfig(fname, data, &part)
+	if err != nil {
+		return nil, err
+	}
+
+	var partErrs ConfigErrors
+
+	notAllowed := func(key string, set bool) {
+		if set {
+			partErrs.add(key, `only feeds and subreddits can be set in included file`)
+		}
+	}
+
+	notAllowed(`subreddit`, part.Subreddit != ``)
+	notAllowed(`dupcheck`, part.DupCheck != ``)
+	notAllowed(`new_feeds`, part.NewFeeds != ``)
+	notAllowed(`new_feeds_newest`, part.NewFeedsNewest != 0)
+	notAllowed(`include`, len(part.Include) > 0)
+
+	if c.Subreddits == nil && len(part.Subreddits) > 0 {
+		c.Subreddits = make(map[string]SubredditLimits)
+	}
+
+	for name, limits := range part.Subreddits {
+		if other := c.subredditFile(name); other != `` {
+			partErrs.add(`subreddits.`+name, `limits for %v are already set in %v`, name, other)
+			continue
+		}
+
+		c.Subreddits[name] = limits
+	}
+
+	partErrs.locate(fname, data, positions)
+	errs = append(errs, partErrs...)
+
+	for i := range part.Feeds {
+		part.Feeds[i].source = fmt.Sprintf(`%v feeds[%v]`, fname, i)
+	}
+
+	c.files = append(c.files, newFeedConfigFile(fname, data, positions, len(c.Feeds), &part))
+	c.Feeds = append(c.Feeds, part.Feeds...)
+
+	return errs, nil
+}
+
+// File which sets limits for subreddit, empty if not set yet
+func (c *FeedConfig) subredditFile(name string) string {
+	for _, f := range c.files {
+		for other := range f.subreddits {
+			if strings.EqualFold(other, name) {
+				return f.Name
+			}
+		}
+	}
+
+	return ``
+}
+
+// Which file has value of merged configuration's path, path is returned relative to that file
+func (c *FeedConfig) fileOf(path string) (file int, filePath string) {
+	var index int
+
+	if _, err := fmt.Sscanf(path, `feeds[%d]`, &index); err == nil {
+		rest := path[strings.Index(path, `]`)+1:]
+
+		for i, f := range c.files {
+			if index >= f.first && index < f.first+f.Feeds {
+				return i, fmt.Sprintf(`feeds[%v]%v`, index-f.first, rest)
+			}
+		}
+	}
+
+	if strings.HasPrefix(path, `subreddits.`) {
+		name := strings.SplitN(strings.TrimPrefix(path, `subreddits.`), `.`, 2)[0]
+
+		for i, f := range c.files {
+			if f.subreddits[name] {
+				return i, path
+			}
+		}
+	}
+
+	return 0, path
+}
+
+// Set file, line and column of validation errors from the file where invalid value is
+func (c *FeedConfig) locateErrors(errs ConfigErrors) (located ConfigErrors) {
+	if len(c.files) == 0 {
+		return errs
+	}
+
+	byFile := make([]ConfigErrors, len(c.files))
+
+	for _, e := range errs {
+		i, path := c.fileOf(e.Path)
+		e.Path = path
+		byFile[i] = append(byFile[i], e)
+	}
+
+	for i, f := range c.files {
+		byFile[i].locate(f.Name, f.data, f.positions)
+		located = append(located, byFile[i]...)
+	}
+
+	return located
+}
+
+// Sort errors by file in merge order and by position within file
+func (c *FeedConfig) sortErrors(errs ConfigErrors) {
+	fileIndex := func(fname string) int {
+		for i, f := range c.files {
+			if f.Name == fname {
+				return i
+			}
+		}
+
+		return len(c.files)
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		fi, fj := fileIndex(errs[i].File), fileIndex(errs[j].File)
+		if fi != fj {
+			return fi < fj
+		}
+
+		return errs[i].before(errs[j])
+	})
+}
+
+// Reference to feed in error messages, includes file name if feeds are from several files
+func (c *FeedConfig) feedRef(i int) string {
+	if c.Feeds[i].source != `` {
+		return c.Feeds[i].source
+	}
+
+	return fmt.Sprintf(`feeds[%v]`, i)
+}
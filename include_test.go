@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIncludeErrorsSorted(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `include`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		`feeds.json`: `{
+  "subreddit": "my_news",
+  "include": ["more.json"],
+  "feeds": [
+    {"title": "news", "url": "ftp://example.com/rss", "maxlen": -1}
+  ]
+}`,
+		`more.json`: `{
+  "feeds": [
+    {"title": "", "url": "https://example.com/more", "bogus": 1}
+  ]
+}`,
+	}
+
+	for name, data := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	top := filepath.Join(dir, `feeds.json`)
+	more := filepath.Join(dir, `more.json`)
+
+	_, err = LoadFeedConfig(top)
+
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf(`expected ConfigErrors, got %v`, err)
+	}
+
+	want := []string{
+		top + `:5:30: feeds[0].url: URL ftp://example.com/rss must use http or https`,
+		top + `:5:65: feeds[0].maxlen: negative maxlen`,
+		more + `:3:15: feeds[0].title: empty title`,
+		more + `:3:54: feeds[0].bogus: unknown field "bogus"`,
+	}
+
+	if len(errs) != len(want) {
+		t.Fatalf(`got %v errors, want %v: %v`, len(errs), len(want), errs)
+	}
+
+	for i, e := range errs {
+		if e.Error() != want[i] {
+			t.Errorf(`error %v = %q, want %q`, i, e.Error(), want[i])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const SUBREDDIT_DEFAULT = `*` // Key for settings used by subreddits without their own settings
+
+// Posting limits for a subreddit
+type SubredditLimits struct {
+	MaxPerHour int    `json:"max_per_hour,omitempty"` // Max posts in any 60 minutes, 0 is unlimited
+	MaxPerDay  int    `json:"max_per_day,omitempty"`  // Max posts in any 24 hours, 0 is unlimited
+	MinGap     string `json:"min_gap,omitempty"`      // Minimum time between two posts, for example 30m
+
+	Windows PostingWindows `json:"windows,omitempty"` // When posting is allowed, empty allows any time
+}
+
+func (l SubredditLimits) Validate() error {
+	if l.MaxPerHour < 0 || l.MaxPerDay < 0 {
+		return fmt.Errorf(`negative post limit`)
+	}
+
+	if l.MinGap != `` {
+		d, err := time.ParseDuration(l.MinGap)
+		if err != nil {
+			return fmt.Errorf(`invalid min_gap: %v`, err)
+		}
+
+		if d < 0 {
+			return fmt.Errorf(`negative min_gap`)
+		}
+	}
+
+	return l.Windows.Validate()
+}
+
+// Earliest time when next post is allowed given earlier submit times
+// Returns now if posting is allowed right away
+func (l SubredditLimits) NextAllowed(history []time.Time, now time.Time) time.Time {
+	next := now
+
+	later := func(t time.Time) {
+		if t.After(next) {
+			next = t
+		}
+	}
+
+	// Max N posts in a sliding window: next post is allowed when the oldest post in the window drops out
+	window := func(max int, length time.Duration) {
+		if max <= 0 {
+			return
+		}
+
+		var inWindow []time.Time
+		for _, t := range history {
+			if t.After(now.Add(-length)) {
+				inWindow = append(inWindow, t)
+			}
+		}
+
+		if len(inWindow) < max {
+			return
+		}
+
+		// history is in ascending order
+		later(inWindow[len(inWindow)-max].Add(length))
+	}
+
+	window(l.MaxPerHour, time.Hour)
+	window(l.MaxPerDay, time.Hour*24)
+
+	if l.MinGap != `` && len(history) > 0 {
+		gap, _ := time.ParseDuration(l.MinGap)
+		later(history[len(history)-1].Add(gap))
+	}
+
+	return next
+}
+
+// Settings for subreddit, falls back to "*" and then to no limits
+func (c *FeedConfig) SubredditSettings(subReddit string) SubredditLimits {
+	for name, l := range c.Subreddits {
+		if strings.EqualFold(name, subReddit) {
+			return l
+		}
+	}
+
+	return c.Subreddits[SUBREDDIT_DEFAULT]
+}
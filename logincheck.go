@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// What feeds need from a subreddit
+type subredditNeeds struct {
+	Name   string
+	Kinds  map[string]bool // Post kinds submitted to subreddit
+	Sticky bool            // Comments are stickied, requires moderator
+}
+
+// Subreddits used in feed configuration, default subreddit is only included if a feed uses it
+func (c *FeedConfig) subredditNeeds() []*subredditNeeds {
+	byName := make(map[string]*subredditNeeds)
+
+	need := func(name string) *subredditNeeds {
+		key := strings.ToLower(name)
+
+		n, ok := byName[key]
+		if !ok {
+			n = &subredditNeeds{
+				Name:  name,
+				Kinds: make(map[string]bool),
+			}
+
+			byName[key] = n
+		}
+
+		return n
+	}
+
+	for _, feed := range c.Feeds {
+		subReddit := feed.Subreddit
+		if subReddit == `` {
+			subReddit = c.Subreddit
+		}
+
+		kind := feed.Kind
+		if kind == `` {
+			kind = KIND_LINK
+		}
+
+		n := need(subReddit)
+		n.Kinds[kind] = true
+
+		if feed.Comment != `` && feed.Sticky {
+			n.Sticky = true
+		}
+
+		for _, target := range feed.Crosspost {
+			need(target).Kinds[KIND_CROSSPOST] = true
+		}
+	}
+
+	var needs []*subredditNeeds
+	for _, n := range byName {
+		needs = append(needs, n)
+	}
+
+	sort.Slice(needs, func(i, j int) bool {
+		return strings.ToLower(needs[i].Name) < strings.ToLower(needs[j].Name)
+	})
+
+	return needs
+}
+
+// Problems preventing the account from posting to subreddit as feeds need
+func (n *subredditNeeds) problems(sub RedditSubreddit) (problems []string) {
+	if sub.UserIsBanned {
+		problems = append(problems, `account is banned`)
+	}
+
+	switch sub.SubredditType {
+	case `public`, ``:
+	case `archived`:
+		problems = append(problems, `subreddit is archived`)
+	default:
+		if !sub.UserIsContributor && !sub.UserIsModerator {
+			problems = append(problems, fmt.Sprintf(`subreddit is %v and account is not an approved submitter`, sub.SubredditType))
+		}
+	}
+
+	if n.Kinds[KIND_LINK] && sub.SubmissionType == KIND_SELF {
+		problems = append(problems, `link posts are not allowed`)
+	}
+
+	if n.Kinds[KIND_SELF] && sub.SubmissionType == KIND_LINK {
+		problems = append(problems, `text posts are not allowed`)
+	}
+
+	if n.Sticky && !sub.UserIsModerator {
+		problems = append(problems, `sticky comments need moderator`)
+	}
+
+	return problems
+}
+
+// Describe subreddit settings relevant for posting
+func describeSubreddit(sub RedditSubreddit) string {
+	desc := []string{sub.SubredditType}
+
+	switch sub.SubmissionType {
+	case `any`:
+		desc = append(desc, `link and text posts`)
+	case KIND_LINK:
+		desc = append(desc, `link posts only`)
+	case KIND_SELF:
+		desc = append(desc, `text posts only`)
+	}
+
+	if sub.UserIsModerator {
+		desc = append(desc, `moderator`)
+	} else if sub.UserIsContributor {
+		desc = append(desc, `approved submitter`)
+	}
+
+	if sub.Quarantine {
+		desc = append(desc, `quarantined`)
+	}
+
+	return strings.Join(desc, `, `)
+}
+
+// Check logged in account, granted scopes and subreddits used by feeds
+// Returns number of problems found
+func loginCheck(ctx context.Context, w io.Writer, redditClient *Reddit, feeds *FeedConfig) (problems int, err error) {
+	account, err := redditClient.Me(ctx)
+	if err != nil {
+		return 0, fmt.Errorf(`couldn't get account: %v`, err)
+	}
+
+	created := account.Created()
+
+	fmt.Fprintf(w, "User:       %v\n", account.Name)
+	fmt.Fprintf(w, "Karma:      %v link, %v comment\n", account.LinkKarma, account.CommentKarma)
+	fmt.Fprintf(w, "Created:    %v (%v days ago)\n", created.Format(`2006-01-02`), int(time.Since(created).Hours()/24))
+
+	if account.IsSuspended {
+		fmt.Fprintf(w, "Suspended:  yes\n")
+		problems++
+	}
+
+	if !account.HasVerifiedEmail {
+		fmt.Fprintf(w, "Email:      not verified\n")
+	}
+
+	granted := strings.Fields(redditClient.Token.Scope)
+	all := len(granted) == 1 && granted[0] == `*`
+
+	// Password login grants all scopes, describe the ones the bot uses
+	scopes := granted
+	if all {
+		scopes = REDDIT_SCOPES
+	}
+
+	descs, err := redditClient.ScopeDescriptions(ctx, scopes)
+	if err != nil {
+		return problems, fmt.Errorf(`couldn't get scopes: %v`, err)
+	}
+
+	if all {
+		fmt.Fprintf(w, "Scopes:     * (all)\n")
+	} else {
+		fmt.Fprintf(w, "Scopes:\n")
+	}
+
+	for _, scope := range scopes {
+		fmt.Fprintf(w, "  %-12v %v\n", scope, descs[scope].Description)
+	}
+
+	if !all {
+		has := make(map[string]bool)
+		for _, scope := range granted {
+			has[scope] = true
+		}
+
+		for _, scope := range REDDIT_SCOPES {
+			if !has[scope] {
+				fmt.Fprintf(w, "  %-12v MISSING, run authorize again\n", scope)
+				problems++
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Subreddits:\n")
+
+	for _, n := range feeds.subredditNeeds() {
+		sub, err := redditClient.SubredditAbout(ctx, n.Name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return problems, ctx.Err()
+			}
+
+			msg := err.Error()
+
+			if e, ok := err.(*ErrorAPI); ok {
+				switch e.status {
+				case http.StatusNotFound:
+					msg = `doesn't exist or is banned`
+				case http.StatusForbidden:
+					msg = `private`
+				}
+			}
+
+			fmt.Fprintf(w, "  %-20v ERROR  %v\n", n.Name, msg)
+			problems++
+
+			continue
+		}
+
+		subProblems := n.problems(sub)
+		if len(subProblems) > 0 {
+			fmt.Fprintf(w, "  %-20v ERROR  %v (%v)\n", n.Name, strings.Join(subProblems, `, `), describeSubreddit(sub))
+			problems += len(subProblems)
+			continue
+		}
+
+		fmt.Fprintf(w, "  %-20v OK     %v\n", n.Name, describeSubreddit(sub))
+	}
+
+	return problems, nil
+}
@@ -2,16 +2,21 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/mmcdole/gofeed"
 	"io/ioutil"
 	"log"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -24,6 +29,7 @@ const (
 	OVERRIDE_SUBMITTED_CHECK = false // for debugging purposes
 	CONFIG_FILE              = `config.json`
 	FEEDS_FILE               = `feeds.json`
+	STATE_DIR                = `.`
 )
 
 type Configuration struct {
@@ -31,44 +37,62 @@ type Configuration struct {
 	Password string `json:"pass"`
 	ClientId string `json:"cid"`
 	Secret   string `json:"secret"`
+
+	// Read value from file instead, see ResolveCredentials
+	UsernameFile string `json:"user_file,omitempty"`
+	PasswordFile string `json:"pass_file,omitempty"`
+	ClientIdFile string `json:"cid_file,omitempty"`
+	SecretFile   string `json:"secret_file,omitempty"`
+
+	// Reddit API base URLs, only needed for testing against a local server
+	AuthUrl string `json:"auth_url,omitempty"`
+	ApiUrl  string `json:"api_url,omitempty"`
+
+	Retry           RetryPolicy `json:"retry"`                      // Retry policy for Reddit API and feed requests
+	QuarantineAfter int         `json:"quarantine_after,omitempty"` // Failed submit attempts before link is not retried anymore
+
+	RefreshToken string `json:"-"` // Loaded from state directory, see authorize command
 }
 
-// Load configuration JSON file
-func LoadConfig(fname string) Configuration {
+// Load configuration file, JSON, YAML or TOML by extension
+func LoadConfig(fname string) (cfg Configuration, err error) {
 	cfgdata, err := ioutil.ReadFile(fname)
 	if err != nil {
-		log.Fatalf(`couldn't open %v'`, fname)
-		panic(err)
+		if os.IsNotExist(err) {
+			// Credentials can be given with environment variables and credential files only
+			log.Printf(`config file %v not found`, fname)
+			return cfg, nil
+		}
+
+		return cfg, err
+	}
+
+	cfgdata, err = configToJSON(fname, cfgdata)
+	if err != nil {
+		return cfg, err
 	}
-	var cfg Configuration
 
 	err = json.Unmarshal(cfgdata, &cfg)
 	if err != nil {
-		panic(err)
+		return cfg, fmt.Errorf(`%v: %w`, fname, err)
 	}
 
-	return cfg
+	return cfg, nil
 }
 
-// Load already submitted cache file
+// Load already submitted cache file, missing file is an empty cache
 // map[URL]submit time
-func LoadSubmitted(fname string) (sub map[string]time.Time) {
+func LoadSubmitted(fname string) (sub map[string]time.Time, err error) {
 	sub = make(map[string]time.Time, 0)
 
 	f, err := os.Open(fname)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// Create new file
-			ftmp, err := os.Create(fname)
-			if err != nil {
-				panic(err)
-			}
-			defer ftmp.Close()
-
-			return LoadSubmitted(fname)
+			// Created on first save
+			return sub, nil
 		}
 
-		panic(err)
+		return nil, fmt.Errorf(`couldn't load cache: %w`, err)
 	}
 
 	defer f.Close()
@@ -79,7 +103,12 @@ func LoadSubmitted(fname string) (sub map[string]time.Time) {
 		sub[scanner.Text()] = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
 	}
 
-	return sub
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf(`couldn't load cache %v: %w`, fname, err)
+	}
+
+	return sub, nil
 }
 
 func (c *Configuration) ValidateConfiguration() (err error) {
@@ -91,6 +120,31 @@ func (c *Configuration) ValidateConfiguration() (err error) {
 		return fmt.Errorf(`empty client id`)
 	}
 
+	for _, u := range []string{c.AuthUrl, c.ApiUrl} {
+		if u == `` {
+			continue
+		}
+
+		pu, err := url.Parse(u)
+		if err != nil || pu.Host == `` || (pu.Scheme != `https` && pu.Scheme != `http`) {
+			return fmt.Errorf(`invalid API base URL %v`, u)
+		}
+	}
+
+	err = c.Retry.Validate()
+	if err != nil {
+		return err
+	}
+
+	if c.QuarantineAfter < 0 {
+		return fmt.Errorf(`negative quarantine_after`)
+	}
+
+	if c.RefreshToken != `` {
+		// User name and password are not needed with refresh token
+		return nil
+	}
+
 	if c.Password == `` {
 		return fmt.Errorf(`empty password`)
 	}
@@ -102,8 +156,26 @@ func (c *Configuration) ValidateConfiguration() (err error) {
 	return nil
 }
 
+// Create Reddit client from configuration
+func (c *Configuration) NewReddit() Reddit {
+	redditClient := New(c.Username, c.Password, c.ClientId, c.Secret, USER_AGENT)
+	redditClient.Token.RefreshToken = c.RefreshToken
+	redditClient.Retry = c.Retry
+
+	if c.AuthUrl != `` {
+		redditClient.AuthUrl = strings.TrimRight(c.AuthUrl, `/`)
+	}
+
+	if c.ApiUrl != `` {
+		redditClient.ApiUrl = strings.TrimRight(c.ApiUrl, `/`)
+	}
+
+	return redditClient
+}
+
 // map[URL]submit time
-func SaveSubmitted(fname string, submitSource map[string]time.Time) {
+// File is replaced atomically so that an interrupted save doesn't lose the cache
+func SaveSubmitted(fname string, submitSource map[string]time.Time) (err error) {
 	// Order the URLs by published date
 	type KeyValuePair struct {
 		Key   string
@@ -122,10 +194,7 @@ func SaveSubmitted(fname string, submitSource map[string]time.Time) {
 		return sortedPairs[i].Value > sortedPairs[j].Value
 	})
 
-	f, err := ioutil.TempFile(`.`, fname)
-	if err != nil {
-		panic(err)
-	}
+	var buf bytes.Buffer
 
 	// Only remember N latest URLs
 	urlsToKeep := 10000
@@ -137,165 +206,371 @@ func SaveSubmitted(fname string, submitSource map[string]time.Time) {
 			break
 		}
 
-		f.WriteString(fmt.Sprintf("%v\n", kv.Key))
+		buf.WriteString(fmt.Sprintf("%v\n", kv.Key))
 		urlsToKeep--
 	}
 
-	f.Close()
-
-	os.Rename(fname, `submitted_old.txt`)
-	os.Rename(f.Name(), fname)
-	os.Remove(`submitted_old.txt`)
+	return writeFileAtomic(fname, buf.Bytes())
 }
 
 func main() {
 	errlog := log.New(os.Stderr, ``, log.LstdFlags)
 
-	configFileArg := flag.String(`config`, CONFIG_FILE, `JSON config file name which has client secrets generated at reddit`)
-	feedFileArg := flag.String(`feed`, FEEDS_FILE, `RSS feed JSON file name`)
+	opts := Options{
+		ConfigFile: CONFIG_FILE,
+		FeedFile:   FEEDS_FILE,
+		StateDir:   STATE_DIR,
+		Redirect:   DEFAULT_REDIRECT,
+		FakeListen: DEFAULT_FAKE_LISTEN,
+	}
 
-	flag.Usage = func() {
-		_, _ = fmt.Fprintf(os.Stdout, "Simple Reddit RSS feed bot %v build %v\n", VERSION, BUILD)
-		_, _ = fmt.Fprintf(os.Stdout, "Homepage <URL: https://github.com/raspi/SimpleRedditRSSBot >\n")
-		_, _ = fmt.Fprintf(os.Stdout, "\n")
-		_, _ = fmt.Fprintf(os.Stdout, "(c) Pekka Järvinen 2018-\n")
-		_, _ = fmt.Fprintln(os.Stdout, `Parameters:`)
+	// Command specific parameters are also accepted before the command for compatibility
+	opts.register(flag.CommandLine)
+	flag.BoolVar(&opts.Daemon, `daemon`, false, `Keep running and poll each feed on its own interval instead of exiting after one pass (run command)`)
+	flag.StringVar(&opts.Redirect, `redirect`, opts.Redirect, `Redirect URI for authorize command, must match the app settings at reddit`)
+	flag.StringVar(&opts.FakeListen, `fake-listen`, opts.FakeListen, `Listen address for serve-fake command`)
+	flag.StringVar(&opts.FakeFeeds, `fake-feeds`, ``, `Directory of static feed files served at /feeds/ by serve-fake command`)
 
-		flag.VisitAll(func(f *flag.Flag) {
-			_, _ = fmt.Fprintf(os.Stdout, "  -%s\n      %s (default: %q)\n", f.Name, f.Usage, f.DefValue)
-		})
-	}
+	flag.Usage = usage
 
 	flag.Parse()
 
-	log.Printf(`Loading config..`)
-	cfg := LoadConfig(*configFileArg)
-	err := cfg.ValidateConfiguration()
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		// Full run like before commands existed
+		args = []string{`run`}
+	}
+
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		groupUsage(args[0])
+		os.Exit(EXIT_USAGE)
+	}
+
+	fs := flag.NewFlagSet(cmd.Name, flag.ExitOnError)
+	opts.register(fs)
+	run := cmd.Setup(fs, &opts)
+	fs.Usage = func() {
+		commandUsage(cmd, fs)
+	}
+
+	_ = fs.Parse(rest)
+
+	stateDir = opts.StateDir
+
+	err := run(ctx, fs.Args(), errlog)
 	if err != nil {
-		panic(err)
+		errlog.Printf(`%v: %v`, cmd.Name, err)
+		os.Exit(exitCode(err))
 	}
+}
 
-	log.Printf(`Loading feeds..`)
-	feeds := LoadFeedConfig(*feedFileArg)
-	err = feeds.ValidateFeedConfig()
+// Fetch feeds, queue new links and submit them, or keep doing that in daemon mode
+// Returned error has exit code of its class, EXIT_PARTIAL if some feeds or submits failed
+func runBot(ctx context.Context, o *Options, errlog *log.Logger) (err error) {
+	cfg, err := o.loadConfig()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	defaultSubReddit := feeds.Subreddit
+	err = cfg.ValidateConfiguration()
+	if err != nil {
+		return withExitCode(EXIT_CONFIG, fmt.Errorf(`%v: %w`, o.ConfigFile, err))
+	}
 
-	var collectedLinks []SubmitLink
+	feeds, err := o.loadFeeds()
+	if err != nil {
+		return err
+	}
 
-	// Collect URLs from feed(s)
-	for _, feedSource := range feeds.Feeds {
-		subReddit := feedSource.Subreddit
+	queue, err := LoadQueue(StatePath(QUEUE_FILE))
+	if err != nil {
+		return fmt.Errorf(`couldn't load queue: %w`, err)
+	}
+
+	feedState, err := LoadFeedState(StatePath(FEED_STATE_FILE))
+	if err != nil {
+		return fmt.Errorf(`couldn't load feed state: %w`, err)
+	}
+
+	err = feedState.Sync(feeds.Feeds)
+	if err != nil {
+		return fmt.Errorf(`couldn't save feed state: %w`, err)
+	}
+
+	redditClient := cfg.NewReddit()
+	redditClient.DebugHTTP = o.DebugHTTP
+
+	submitter := Submitter{
+		Reddit:          &redditClient,
+		Queue:           queue,
+		Feeds:           &feeds,
+		QuarantineAfter: cfg.QuarantineAfter,
+		Pause:           SUBMIT_PAUSE,
+		errlog:          errlog,
+	}
 
-		if subReddit == `` {
-			subReddit = defaultSubReddit
+	if submitter.QuarantineAfter == 0 {
+		submitter.QuarantineAfter = DEFAULT_QUARANTINE_AFTER
+	}
+
+	// RSS HTTP client
+	feedClient := &http.Client{
+		Timeout: time.Second * 30,
+	}
+
+	if o.Daemon {
+		d := Daemon{
+			Feeds:      &feeds,
+			FeedState:  feedState,
+			FeedClient: feedClient,
+			Submitter:  &submitter,
+			Retry:      cfg.Retry,
+			errlog:     errlog,
 		}
 
-		// RSS HTTP client
-		fp := gofeed.NewParser()
+		d.Run(ctx)
+		return interrupted(ctx, queue, errlog)
+	}
+
+	var collectedLinks []SubmitLink
 
-		feed, err := fp.ParseURL(feedSource.UrlAddress)
-		if err != nil {
-			errlog.Printf(`error: feed '%v' URL %v parse error: %v`, feedSource.Title, feedSource.UrlAddress, err)
-			continue
+	failedFeeds := 0
+
+	// Collect URLs from feed(s)
+	for _, feedSource := range feeds.Feeds {
+		if ctx.Err() != nil {
+			break
 		}
 
-		for _, item := range feed.Items {
-			link, err := url.Parse(item.Link)
-			if err != nil {
-				errlog.Printf(`error: parsing URL %v - %v`, item.Link, err)
-				continue
-			}
-
-			// Do a DNS lookup if URL has broken address
-			ips, err := net.LookupIP(link.Host)
-			if err != nil {
-				errlog.Printf(`error: DNS lookup %v - %v`, item.Link, err)
-				continue
-			}
-
-			if len(ips) == 0 {
-				// Broken domain without IP address(es)
-				errlog.Printf(`error: couldn't resolve IP address for %v`, link.String())
-				continue
-			}
-
-			sl := SubmitLink{
-				Title:     item.Title,
-				Url:       link.String(),
-				SubReddit: subReddit,
-				Published: *item.PublishedParsed,
-			}
-
-			collectedLinks = append(collectedLinks, sl)
+		links, ok := pollFeed(ctx, feedClient, &feeds, feedState, feedSource, cfg.Retry, errlog)
+		if !ok {
+			failedFeeds++
 		}
+
+		collectedLinks = append(collectedLinks, links...)
 	}
 
 	log.Printf(`Got %v URLs from feeds..`, len(collectedLinks))
 
 	log.Printf(`Removing cached..`)
-	var submitLinks []SubmitLink
+	added, err := enqueueLinks(queue, collectedLinks)
+	if err != nil {
+		return err
+	}
+
+	// Free memory
+	collectedLinks = []SubmitLink{}
+
+	err = queue.Save()
+	if err != nil {
+		return fmt.Errorf(`couldn't save queue: %w`, err)
+	}
 
-	// Remove cached
-	for _, link := range collectedLinks {
-		log.Printf(`Loading submitted cache..`)
-		submitted := LoadSubmitted(fmt.Sprintf(`%s.cache`, link.SubReddit))
+	log.Printf(`Added %v URLs to queue, %v URLs due for submitting..`, added, len(queue.Due(time.Now())))
 
-		// Check local cache
-		_, ok := submitted[link.Url]
+	// Submit new links to Reddit
+	err = submitter.SubmitDue(ctx)
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf(`submit failed: %w`, err)
+	}
 
-		if ok && !OVERRIDE_SUBMITTED_CHECK {
-			continue
+	err = interrupted(ctx, queue, errlog)
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+
+	if failedFeeds > 0 {
+		failures = append(failures, fmt.Sprintf(`%v feeds failed`, failedFeeds))
+	}
+
+	if submitter.Failed > 0 {
+		failures = append(failures, fmt.Sprintf(`%v submits failed, see %v`, submitter.Failed, queue.fname))
+	}
+
+	if submitter.Stopped {
+		failures = append(failures, fmt.Sprintf(`submitting stopped early, %v links left in queue`, queue.Count(STATUS_PENDING)))
+	}
+
+	if len(failures) > 0 {
+		return withExitCode(EXIT_PARTIAL, errors.New(strings.Join(failures, `, `)))
+	}
+
+	return nil
+}
+
+// Save state and return error with EXIT_INTERRUPTED if stopped by signal
+func interrupted(ctx context.Context, queue *Queue, errlog *log.Logger) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	err := queue.Save()
+	if err != nil {
+		errlog.Printf(`error: saving queue: %v`, err)
+	}
+
+	return withExitCode(EXIT_INTERRUPTED, fmt.Errorf(`interrupted, %v URLs left in queue`, queue.Count(STATUS_PENDING)))
+}
+
+// Submit link built from feed item
+type FeedItemLink struct {
+	Link SubmitLink
+	Ips  []net.IP // Addresses of link's host
+	Skip string   // Why item is not submitted, empty if it's ok
+}
+
+// Fetch feed and build submit links from its items, broken items are returned with Skip set
+// Returns error if feed couldn't be fetched
+func buildFeedLinks(ctx context.Context, feedClient *http.Client, feeds *FeedConfig, feedSource FeedSource, retry RetryPolicy) (items []FeedItemLink, err error) {
+	subReddit := feedSource.Subreddit
+
+	if subReddit == `` {
+		subReddit = feeds.Subreddit
+	}
+
+	// Validated already
+	dupCheck, _ := feeds.DupCheckWindow(feedSource)
+
+	feed, err := FetchFeed(ctx, feedClient, feedSource.UrlAddress, retry)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range feed.Items {
+		var fl FeedItemLink
+
+		fl.Link = SubmitLink{
+			Title:     SanitizeTitle(item.Title),
+			Url:       item.Link,
+			SubReddit: subReddit,
+			Published: time.Now(),
+			Kind:      KIND_LINK,
+			Crosspost: feedSource.Crosspost,
+			FlairId:   feedSource.FlairId,
+			Flair:     feedSource.Flair,
+			DupCheck:  dupCheck,
+			Windows:   feedSource.Windows,
+		}
+
+		// Not all feeds have publish dates
+		if item.PublishedParsed != nil {
+			fl.Link.Published = *item.PublishedParsed
+		} else if item.UpdatedParsed != nil {
+			fl.Link.Published = *item.UpdatedParsed
 		}
 
-		submitLinks = append(submitLinks, link)
+		fl.Skip = buildFeedLink(&fl, feedSource, item)
+		items = append(items, fl)
 	}
 
-	// Free memory
-	collectedLinks = []SubmitLink{}
+	return items, nil
+}
 
-	log.Printf(`Got %v URLs for submitting..`, len(submitLinks))
+// Check feed item and fill in link details
+// Returns reason if item should be skipped
+func buildFeedLink(fl *FeedItemLink, feedSource FeedSource, item *gofeed.Item) string {
+	link, err := url.Parse(item.Link)
+	if err != nil {
+		return fmt.Sprintf(`invalid URL: %v`, err)
+	}
 
-	// Submit new links to Reddit
-	redditClient := New(cfg.Username, cfg.Password, cfg.ClientId, cfg.Secret, USER_AGENT)
+	fl.Link.Url = link.String()
+
+	// Do a DNS lookup if URL has broken address
+	fl.Ips, err = net.LookupIP(link.Hostname())
+	if err != nil {
+		return fmt.Sprintf(`DNS lookup failed: %v`, err)
+	}
+
+	if len(fl.Ips) == 0 {
+		// Broken domain without IP address(es)
+		return `couldn't resolve IP address`
+	}
+
+	if fl.Link.Title == `` {
+		return `empty title`
+	}
+
+	tplData := NewItemTemplateData(feedSource, item, fl.Link)
 
-	if len(submitLinks) > 0 {
-		// Log in for submitting
-		log.Printf(`Logging in..`)
-		err = redditClient.Login()
+	if feedSource.Kind == KIND_SELF {
+		fl.Link.Kind = KIND_SELF
+		fl.Link.Text, err = BuildSelfText(feedSource, tplData)
 		if err != nil {
-			errlog.Fatalf(`Login failed: %v`, err)
+			return fmt.Sprintf(`building text post failed: %v`, err)
 		}
 	}
 
-	for _, link := range submitLinks {
-		log.Printf(`Submitting to %v: %v [%v] - %v`, link.SubReddit, link.Title, link.Published, link.Url)
-
-		// Submit link
-		err = redditClient.SubmitLink(link)
+	if feedSource.Comment != `` {
+		fl.Link.Comment, err = BuildComment(feedSource, tplData)
 		if err != nil {
-			serr, ok := err.(*ErrorSubmitExists)
+			return fmt.Sprintf(`building comment failed: %v`, err)
+		}
+
+		fl.Link.Sticky = feedSource.Sticky
+	}
+
+	// Templates see the item's own title
+	fl.Link.Title = DecorateTitle(feedSource.Prefix, fl.Link.Title, feedSource.Suffix)
+
+	return ``
+}
+
+// Fetch feed and build submit links from its items
+// Returns error if feed couldn't be fetched, broken items are logged and skipped
+func collectFeed(ctx context.Context, feedClient *http.Client, feeds *FeedConfig, feedSource FeedSource, retry RetryPolicy, errlog *log.Logger) (links []SubmitLink, err error) {
+	items, err := buildFeedLinks(ctx, feedClient, feeds, feedSource, retry)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fl := range items {
+		if fl.Skip != `` {
+			errlog.Printf(`error: feed '%v' item %v skipped: %v`, feedSource.Title, fl.Link.Url, fl.Skip)
+			continue
+		}
 
-			if ok {
-				submitFile := fmt.Sprintf(`%s.cache`, link.SubReddit)
+		links = append(links, fl.Link)
+	}
 
-				errlog.Printf("Already submitted: %v - %#v", link.Url, serr)
-				submitted := LoadSubmitted(submitFile)
-				submitted[serr.link.Url] = serr.link.Published
+	return links, nil
+}
 
-				log.Printf(`Saving submitted cache..`)
-				SaveSubmitted(submitFile, submitted)
-			} else {
-				log.Fatalf(`%v`, err)
-			}
+// Fetch feed and apply new feed policy if feed wasn't seen before
+// Errors are logged, ok is false if the feed failed
+func pollFeed(ctx context.Context, feedClient *http.Client, feeds *FeedConfig, feedState *FeedState, feedSource FeedSource, retry RetryPolicy, errlog *log.Logger) (links []SubmitLink, ok bool) {
+	links, err := collectFeed(ctx, feedClient, feeds, feedSource, retry, errlog)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Stopped, not a feed error
+			return nil, true
 		}
 
-		// Sleep so that API isn't overloaded and bot doesn't get banned
-		time.Sleep(time.Second * 2)
+		errlog.Printf(`error: feed '%v' URL %v parse error: %v`, feedSource.Title, feedSource.UrlAddress, err)
+		return nil, false
+	}
+
+	if !feedState.IsNew(feedSource) {
+		return links, true
+	}
 
+	links, err = feeds.newFeedLinks(feedSource, links)
+	if err != nil {
+		// Policy is applied again on next fetch
+		errlog.Printf(`error: feed '%v' adding items to cache: %v`, feedSource.Title, err)
+		return nil, false
+	}
+
+	err = feedState.Seen(feedSource)
+	if err != nil {
+		errlog.Printf(`error: saving feed state: %v`, err)
 	}
 
+	return links, true
 }
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const markdownLineBreak = "\x01" // Placeholder for <br> until lines are cleaned up
+
+var (
+	markdownEscaper    = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, `~`, `\~`, `^`, `\^`, `[`, `\[`, `]`, `\]`, "`", "\\`")
+	markdownBlankLines = regexp.MustCompile(`\n{3,}`)
+	markdownLinks      = regexp.MustCompile(`!?\[(?:\\.|[^\]\\])*\]\([^)]*\)`) // Links and images, link text may have escaped brackets
+)
+
+// Convert HTML (feed item description or content) to Reddit flavored markdown
+func HTMLToMarkdown(s string) string {
+	nodes, err := xhtml.ParseFragment(strings.NewReader(s), &xhtml.Node{
+		Type:     xhtml.ElementNode,
+		Data:     `body`,
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		// Not HTML at all, use as plain text
+		return strings.TrimSpace(markdownEscaper.Replace(s))
+	}
+
+	var sb strings.Builder
+	for _, n := range nodes {
+		sb.WriteString(markdownNode(n, false))
+	}
+
+	// Clean up whitespace around lines
+	lines := strings.Split(sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+
+	md := strings.Join(lines, "\n")
+	md = strings.Replace(md, " "+markdownLineBreak, markdownLineBreak, -1)
+	md = strings.Replace(md, markdownLineBreak+" ", markdownLineBreak, -1)
+	md = strings.Replace(md, markdownLineBreak, "  \n", -1)
+	md = markdownBlankLines.ReplaceAllString(md, "\n\n")
+
+	return strings.TrimSpace(md)
+}
+
+// Render children of a node
+func markdownChildren(n *xhtml.Node, pre bool) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(markdownNode(c, pre))
+	}
+
+	return sb.String()
+}
+
+// Render a single node and its children
+func markdownNode(n *xhtml.Node, pre bool) string {
+	switch n.Type {
+	case xhtml.TextNode:
+		if pre {
+			return n.Data
+		}
+
+		text := strings.Join(strings.Fields(n.Data), ` `)
+		if text == `` {
+			if strings.TrimSpace(n.Data) != n.Data && n.Data != `` {
+				return ` `
+			}
+			return ``
+		}
+
+		// Keep single space at the edges so that inline elements don't get glued together
+		if strings.IndexAny(n.Data[:1], " \t\r\n") == 0 {
+			text = ` ` + text
+		}
+
+		if strings.IndexAny(n.Data[len(n.Data)-1:], " \t\r\n") == 0 {
+			text += ` `
+		}
+
+		return markdownEscaper.Replace(text)
+	case xhtml.ElementNode:
+	default:
+		return markdownChildren(n, pre)
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Iframe, atom.Object, atom.Noscript:
+		return ``
+	case atom.Br:
+		return markdownLineBreak
+	case atom.Hr:
+		return "\n\n***\n\n"
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Figure, atom.Table, atom.Tr:
+		return "\n\n" + strings.TrimSpace(markdownChildren(n, pre)) + "\n\n"
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		return fmt.Sprintf("\n\n%v %v\n\n", strings.Repeat(`#`, level), strings.TrimSpace(markdownChildren(n, pre)))
+	case atom.B, atom.Strong:
+		return markdownWrap(markdownChildren(n, pre), `**`)
+	case atom.I, atom.Em, atom.Cite:
+		return markdownWrap(markdownChildren(n, pre), `*`)
+	case atom.S, atom.Strike, atom.Del:
+		return markdownWrap(markdownChildren(n, pre), `~~`)
+	case atom.Sup:
+		text := strings.TrimSpace(markdownChildren(n, pre))
+		if text == `` {
+			return ``
+		}
+		return `^(` + text + `)`
+	case atom.Code:
+		if pre {
+			return markdownChildren(n, pre)
+		}
+		return markdownWrap(markdownText(n), "`")
+	case atom.Pre:
+		lines := strings.Split(strings.Trim(markdownText(n), "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = `    ` + line
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	case atom.Blockquote:
+		lines := strings.Split(markdownBlock(markdownChildren(n, pre)), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(`> `+strings.TrimSpace(line), ` `)
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	case atom.Ul, atom.Ol:
+		return "\n\n" + markdownList(n) + "\n\n"
+	case atom.A:
+		text := strings.TrimSpace(markdownChildren(n, pre))
+		href := markdownAttr(n, `href`)
+		if href == `` || strings.HasPrefix(href, `#`) || strings.HasPrefix(strings.ToLower(href), `javascript:`) {
+			return text
+		}
+
+		if text == `` {
+			text = markdownEscaper.Replace(href)
+		}
+
+		return fmt.Sprintf(`[%v](%v)`, text, markdownURL(href))
+	case atom.Img:
+		src := markdownAttr(n, `src`)
+		if src == `` {
+			return ``
+		}
+
+		alt := strings.TrimSpace(markdownAttr(n, `alt`))
+		if alt == `` {
+			alt = `image`
+		}
+
+		return fmt.Sprintf(`[%v](%v)`, markdownEscaper.Replace(alt), markdownURL(src))
+	}
+
+	return markdownChildren(n, pre)
+}
+
+// Render list items of <ul> or <ol>
+func markdownList(n *xhtml.Node) string {
+	var items []string
+	idx := 1
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != xhtml.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := `*`
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf(`%d.`, idx)
+			idx++
+		}
+
+		lines := strings.Split(markdownBlock(markdownChildren(c, false)), "\n")
+		for i := range lines {
+			if i == 0 {
+				lines[i] = marker + ` ` + lines[i]
+				continue
+			}
+
+			if lines[i] != `` {
+				// Nested content is indented under the list item
+				lines[i] = `    ` + lines[i]
+			}
+		}
+
+		items = append(items, strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// Clean up rendered block content so that its lines can be prefixed (quotes, list items)
+func markdownBlock(s string) string {
+	s = strings.Replace(s, markdownLineBreak, "  \n", -1)
+	s = markdownBlankLines.ReplaceAllString(strings.TrimSpace(s), "\n\n")
+
+	return s
+}
+
+// Wrap inline content in markdown emphasis markers, keeping surrounding whitespace outside the markers
+func markdownWrap(s string, marker string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == `` {
+		return s
+	}
+
+	lead := s[:strings.Index(s, trimmed)]
+	trail := s[len(lead)+len(trimmed):]
+
+	return lead + marker + trimmed + marker + trail
+}
+
+// Raw text content of a node without markdown escaping
+func markdownText(n *xhtml.Node) string {
+	if n.Type == xhtml.TextNode {
+		return n.Data
+	}
+
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(markdownText(c))
+	}
+
+	return sb.String()
+}
+
+func markdownAttr(n *xhtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+
+	return ``
+}
+
+// Escape characters which would end markdown link target
+func markdownURL(u string) string {
+	return strings.NewReplacer(`(`, `%28`, `)`, `%29`, ` `, `%20`).Replace(u)
+}
+
+// Shorten markdown text to at most max characters
+// Tries to cut at paragraph or word boundary and appends an ellipsis
+func TruncateMarkdown(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	// Leave room for the ellipsis
+	runes := []rune(s)
+	cut := string(runes[:max-1])
+
+	if idx := strings.LastIndex(cut, "\n\n"); idx > len(cut)/2 {
+		// Whole paragraphs fit
+		return strings.TrimSpace(cut[:idx]) + "\n\n…"
+	}
+
+	if idx := strings.LastIndexAny(cut, " \n"); idx > len(cut)/2 {
+		cut = cut[:idx]
+	}
+
+	// Don't cut inside a link, "[link text that is…" isn't valid markdown
+	for _, loc := range markdownLinks.FindAllStringIndex(s, -1) {
+		if loc[0] < len(cut) && len(cut) < loc[1] {
+			cut = cut[:loc[0]]
+			break
+		}
+	}
+
+	// Don't leave dangling escape character
+	cut = strings.TrimRight(cut, ` \`)
+
+	return cut + `…`
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestTruncateMarkdown(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{`Short text`, 20, `Short text`},
+		{`Some words that are too long`, 16, `Some words…`},
+		{`Read [link text that is long](https://example.com/a) now`, 30, `Read…`},
+		{`Read more at [link \] text](https://example.com/a)`, 40, `Read more at…`},
+		{`A [link](https://example.com/) and more words here`, 34, `A [link](https://example.com/)…`},
+		{"First paragraph here.\n\nSecond [paragraph](https://example.com/) is long", 40, "First paragraph here.\n\n…"},
+	}
+
+	for _, tt := range tests {
+		got := TruncateMarkdown(tt.in, tt.max)
+		if got != tt.want {
+			t.Errorf(`TruncateMarkdown(%q, %v) = %q, want %q`, tt.in, tt.max, got, tt.want)
+		}
+	}
+}
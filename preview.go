@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Verdicts of feed items in feed preview
+const (
+	VERDICT_SUBMIT = `submit` // Would be queued and submitted
+	VERDICT_SKIP   = `skip`   // Not submitted, see reason
+	VERDICT_SEED   = `seed`   // Feed is new, item is only added to cache because of new_feeds policy
+	VERDICT_QUEUED = `queued` // Already in submission queue
+)
+
+// Feed as the bot would see it on next run
+type FeedPreview struct {
+	Title     string        `json:"title"`
+	Url       string        `json:"url"`
+	New       bool          `json:"new"`                 // Feed hasn't been fetched before or its URL has changed
+	NewFeeds  string        `json:"new_feeds,omitempty"` // Policy for new feed
+	Subreddit string        `json:"subreddit"`
+	Crosspost []string      `json:"crosspost,omitempty"`
+	Items     []ItemPreview `json:"items"`
+}
+
+// Feed item as it would be submitted
+type ItemPreview struct {
+	Title     string    `json:"title"` // Sanitized title with feed's prefix and suffix
+	Url       string    `json:"url"`
+	Ips       []string  `json:"ips,omitempty"` // Addresses of URL's host
+	Published time.Time `json:"published"`
+	Kind      string    `json:"kind"`
+	FlairId   string    `json:"fid,omitempty"`
+	Flair     string    `json:"flair,omitempty"`
+	Text      string    `json:"text,omitempty"`    // Text post body
+	Comment   string    `json:"comment,omitempty"` // First comment
+	Verdict   string    `json:"verdict"`
+	Reason    string    `json:"reason,omitempty"`
+	Cache     string    `json:"cache"` // new, cached or queue status
+}
+
+// Build preview of feed's items without touching Reddit or state
+func previewFeed(feeds *FeedConfig, feedSource FeedSource, items []FeedItemLink, feedState *FeedState, queue *Queue) (p FeedPreview, err error) {
+	p = FeedPreview{
+		Title:     feedSource.Title,
+		Url:       feedSource.UrlAddress,
+		New:       feedState.IsNew(feedSource),
+		Subreddit: feedSource.Subreddit,
+		Crosspost: feedSource.Crosspost,
+		Items:     []ItemPreview{},
+	}
+
+	if p.Subreddit == `` {
+		p.Subreddit = feeds.Subreddit
+	}
+
+	var links []SubmitLink
+	for _, fl := range items {
+		if fl.Skip == `` {
+			links = append(links, fl.Link)
+		}
+	}
+
+	seeded := make(map[string]bool)
+
+	if p.New {
+		p.NewFeeds = feeds.NewFeeds
+		if p.NewFeeds == `` {
+			p.NewFeeds = NEW_FEEDS_ALL
+		}
+
+		_, seed := feeds.splitNewFeedLinks(links)
+		for _, link := range seed {
+			seeded[link.Url] = true
+		}
+	}
+
+	submitted, err := LoadSubmitted(cacheFile(p.Subreddit))
+	if err != nil {
+		return p, err
+	}
+
+	for _, fl := range items {
+		ip := ItemPreview{
+			Title:     fl.Link.Title,
+			Url:       fl.Link.Url,
+			Published: fl.Link.Published,
+			Kind:      fl.Link.Kind,
+			FlairId:   fl.Link.FlairId,
+			Flair:     fl.Link.Flair,
+			Text:      fl.Link.Text,
+			Comment:   fl.Link.Comment,
+			Verdict:   VERDICT_SUBMIT,
+			Cache:     `new`,
+		}
+
+		for _, addr := range fl.Ips {
+			ip.Ips = append(ip.Ips, addr.String())
+		}
+
+		_, cached := submitted[fl.Link.Url]
+		queued := queue.Find(fl.Link.SubReddit, fl.Link.Url)
+
+		switch {
+		case queued != nil:
+			ip.Cache = queued.Status
+		case cached:
+			ip.Cache = `cached`
+		}
+
+		switch {
+		case fl.Skip != ``:
+			ip.Verdict = VERDICT_SKIP
+			ip.Reason = fl.Skip
+		case queued != nil:
+			ip.Verdict = VERDICT_QUEUED
+			ip.Reason = queued.Reason
+		case cached:
+			ip.Verdict = VERDICT_SKIP
+			ip.Reason = `already submitted`
+		case seeded[fl.Link.Url]:
+			ip.Verdict = VERDICT_SEED
+			ip.Reason = fmt.Sprintf(`new feed, new_feeds is %v`, p.NewFeeds)
+		}
+
+		p.Items = append(p.Items, ip)
+	}
+
+	return p, nil
+}
+
+func (p FeedPreview) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent(``, `  `)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(p)
+}
+
+func (p FeedPreview) WriteText(w io.Writer) error {
+	orNone := func(s string) string {
+		if s == `` {
+			return `-`
+		}
+
+		return s
+	}
+
+	var err error
+
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	// Indent multi-line text under its label
+	block := func(s string) string {
+		return strings.Replace(s, "\n", "\n             ", -1)
+	}
+
+	printf("Feed:        %v\n", p.Title)
+	printf("URL:         %v\n", p.Url)
+	printf("Subreddit:   %v\n", p.Subreddit)
+
+	if len(p.Crosspost) > 0 {
+		printf("Crosspost:   %v\n", strings.Join(p.Crosspost, `, `))
+	}
+
+	if p.New {
+		printf("New feed:    yes, new_feeds is %v\n", p.NewFeeds)
+	}
+
+	printf("Items:       %v\n", len(p.Items))
+
+	for i, item := range p.Items {
+		printf("\n[%v] %v\n", i+1, item.Title)
+		printf("  URL:       %v\n", item.Url)
+		printf("  IPs:       %v\n", orNone(strings.Join(item.Ips, `, `)))
+		printf("  Published: %v\n", item.Published.Format(time.RFC3339))
+		printf("  Kind:      %v\n", item.Kind)
+
+		if item.FlairId != `` {
+			printf("  Flair:     %v (%v)\n", orNone(item.Flair), item.FlairId)
+		}
+
+		printf("  Cache:     %v\n", item.Cache)
+
+		if item.Reason != `` {
+			printf("  Verdict:   %v (%v)\n", item.Verdict, item.Reason)
+		} else {
+			printf("  Verdict:   %v\n", item.Verdict)
+		}
+
+		if item.Text != `` {
+			printf("  Text:      %v\n", block(item.Text))
+		}
+
+		if item.Comment != `` {
+			printf("  Comment:   %v\n", block(item.Comment))
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	QUEUE_FILE = `queue.json` // Submission queue file name in state directory
+
+	// Queue item status
+	STATUS_PENDING   = `pending`   // Waiting to be submitted
+	STATUS_SUBMITTED = `submitted` // Submitted to Reddit
+	STATUS_FAILED    = `failed`    // Failed too many times, not retried anymore
+	STATUS_SKIPPED   = `skipped`   // Not submitted, for example already in Reddit
+
+	QUEUE_KEEP_DONE   = time.Hour * 24 * 30 // How long submitted and skipped items are kept as submission history
+	QUEUE_RETRY_DELAY = time.Minute * 10    // Delay before retrying failed submit, doubled for each attempt
+	QUEUE_RETRY_MAX   = time.Hour * 24      // Upper limit for retry delay
+
+	DEFAULT_QUARANTINE_AFTER = 3 // Failed submit attempts before link is not retried anymore
+)
+
+// Link in submission queue
+type QueueItem struct {
+	Link        SubmitLink `json:"link"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`               // Failed attempts so far
+	Reason      string     `json:"reason,omitempty"`       // Error from last failed or interrupted attempt or why item was skipped
+	NextAttempt time.Time  `json:"next_attempt"`           // Not submitted before this
+	CrosspostOf string     `json:"crosspost_of,omitempty"` // Fullname of the original post for crossposts
+	Post        string     `json:"post,omitempty"`         // Fullname of post after submitting
+	CommentDue  bool       `json:"comment_due,omitempty"`  // First comment was refused for now and is posted on next run
+	Added       time.Time  `json:"added"`                  // When item was added to queue
+	Updated     time.Time  `json:"updated"`                // Last status change
+}
+
+// Persistent submission queue
+// Feed stage adds links and submit stage drains them so that a run can continue where the previous one stopped
+type Queue struct {
+	Items []*QueueItem `json:"items"`
+
+	fname string
+}
+
+func queueKey(subReddit string, linkUrl string) string {
+	return subReddit + ` ` + linkUrl
+}
+
+// Load queue file
+// Returns empty queue if file doesn't exist
+func LoadQueue(fname string) (*Queue, error) {
+	q := &Queue{
+		fname: fname,
+	}
+
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return q, nil
+		}
+
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Save queue file, submitted and skipped items older than QUEUE_KEEP_DONE are dropped
+// Failed items are kept as they aren't in submitted cache and would be added again from feed
+func (q *Queue) Save() error {
+	limit := time.Now().Add(-QUEUE_KEEP_DONE)
+
+	var items []*QueueItem
+	for _, item := range q.Items {
+		done := item.Status == STATUS_SUBMITTED || item.Status == STATUS_SKIPPED
+
+		if done && item.Updated.Before(limit) {
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	q.Items = items
+
+	data, err := json.MarshalIndent(q, ``, `  `)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(q.fname, data)
+}
+
+// Find item by subreddit and URL
+func (q *Queue) Find(subReddit string, linkUrl string) *QueueItem {
+	key := queueKey(subReddit, linkUrl)
+
+	for _, item := range q.Items {
+		if queueKey(item.Link.SubReddit, item.Link.Url) == key {
+			return item
+		}
+	}
+
+	return nil
+}
+
+// Add link to queue as pending
+// Returns nil if link is already in queue for the subreddit
+func (q *Queue) Add(link SubmitLink) *QueueItem {
+	if q.Find(link.SubReddit, link.Url) != nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	item := &QueueItem{
+		Link:        link,
+		Status:      STATUS_PENDING,
+		NextAttempt: now,
+		Added:       now,
+		Updated:     now,
+	}
+
+	q.Items = append(q.Items, item)
+
+	return item
+}
+
+// Remove item from queue
+func (q *Queue) Remove(item *QueueItem) {
+	for i, it := range q.Items {
+		if it == item {
+			q.Items = append(q.Items[:i], q.Items[i+1:]...)
+			return
+		}
+	}
+}
+
+// Pending items which can be submitted at given time, oldest first
+func (q *Queue) Due(now time.Time) []*QueueItem {
+	var items []*QueueItem
+
+	for _, item := range q.Items {
+		if item.Status == STATUS_PENDING && !item.NextAttempt.After(now) {
+			items = append(items, item)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Added.Before(items[j].Added)
+	})
+
+	return items
+}
+
+// Submitted items whose first comment is posted again
+func (q *Queue) CommentsDue() []*QueueItem {
+	var items []*QueueItem
+
+	for _, item := range q.Items {
+		if item.Status == STATUS_SUBMITTED && item.CommentDue {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Earliest next attempt of pending items, false if there are no pending items
+func (q *Queue) NextDue() (next time.Time, ok bool) {
+	for _, item := range q.Items {
+		if item.Status != STATUS_PENDING {
+			continue
+		}
+
+		if !ok || item.NextAttempt.Before(next) {
+			next = item.NextAttempt
+			ok = true
+		}
+	}
+
+	return next, ok
+}
+
+// Count items by status
+func (q *Queue) Count(status string) (count int) {
+	for _, item := range q.Items {
+		if item.Status == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Submit times of subreddit's submitted items in ascending order
+func (q *Queue) SubmitHistory(subReddit string) []time.Time {
+	var history []time.Time
+
+	for _, item := range q.Items {
+		if item.Status == STATUS_SUBMITTED && strings.EqualFold(item.Link.SubReddit, subReddit) {
+			history = append(history, item.Updated)
+		}
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Before(history[j])
+	})
+
+	return history
+}
+
+// Mark item submitted
+func (item *QueueItem) Submitted(post string) {
+	item.Status = STATUS_SUBMITTED
+	item.Post = post
+	item.Reason = ``
+	item.Updated = time.Now()
+}
+
+// Mark item skipped
+func (item *QueueItem) Skipped(reason string) {
+	item.Status = STATUS_SKIPPED
+	item.Reason = reason
+	item.Updated = time.Now()
+}
+
+// Make item pending again with no failed attempts
+func (item *QueueItem) Retry() {
+	now := time.Now()
+
+	item.Status = STATUS_PENDING
+	item.Attempts = 0
+	item.Reason = ``
+	item.NextAttempt = now
+	item.Updated = now
+}
+
+// Record attempt with unknown result, not counted as failed attempt
+func (item *QueueItem) Interrupted(reason error) {
+	item.Reason = reason.Error()
+	item.Updated = time.Now()
+}
+
+// Record failed attempt
+// Item is retried later with backoff until it has failed maxAttempts times
+func (item *QueueItem) Failed(reason error, maxAttempts int) {
+	now := time.Now()
+
+	item.Attempts++
+	item.Reason = reason.Error()
+	item.Updated = now
+
+	if maxAttempts > 0 && item.Attempts >= maxAttempts {
+		item.Status = STATUS_FAILED
+		return
+	}
+
+	delay := QUEUE_RETRY_DELAY
+	for i := 1; i < item.Attempts && delay < QUEUE_RETRY_MAX; i++ {
+		delay *= 2
+	}
+
+	if delay > QUEUE_RETRY_MAX {
+		delay = QUEUE_RETRY_MAX
+	}
+
+	item.NextAttempt = now.Add(delay)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	REDACTED         = `[REDACTED]`
+	BODY_EXCERPT_LEN = 200 // How much of response body is included in errors without -debug-http
+)
+
+// Form and JSON keys which always have secret values
+var redactKeys = []string{`password`, `pass`, `secret`, `refresh_token`, `access_token`, `code`}
+
+var redactPatterns = []*regexp.Regexp{
+	// JSON: "access_token": "..."
+	regexp.MustCompile(`("(?:` + strings.Join(redactKeys, `|`) + `)"\s*:\s*")[^"]*`),
+	// Form or query: password=...
+	regexp.MustCompile(`((?:^|[?&\s])(?:` + strings.Join(redactKeys, `|`) + `)=)[^&\s]*`),
+	// Authorization header
+	regexp.MustCompile(`(?i)((?:bearer|basic)\s+)[A-Za-z0-9._~+/=-]+`),
+}
+
+// Known secret values of the client
+func (r *Reddit) secrets() []string {
+	var secrets []string
+
+	for _, s := range []string{r.Password, r.Secret, r.Id, r.Token.Id, r.Token.RefreshToken} {
+		if len(s) < 4 {
+			// Too short to be redacted without mangling unrelated text
+			continue
+		}
+
+		secrets = append(secrets, s, url.QueryEscape(s))
+	}
+
+	return secrets
+}
+
+// Remove credentials and tokens from text
+func (r *Reddit) Redact(s string) string {
+	for _, secret := range r.secrets() {
+		s = strings.Replace(s, secret, REDACTED, -1)
+	}
+
+	for _, re := range redactPatterns {
+		s = re.ReplaceAllString(s, `${1}`+REDACTED)
+	}
+
+	return s
+}
+
+// Log with credentials removed
+func (r *Reddit) logf(format string, args ...interface{}) {
+	log.Print(r.Redact(fmt.Sprintf(format, args...)))
+}
+
+// Error with credentials removed
+func (r *Reddit) errorf(format string, args ...interface{}) error {
+	return errors.New(r.Redact(fmt.Sprintf(format, args...)))
+}
+
+// Log full request and response body when -debug-http is used
+func (r *Reddit) debugHTTP(req *http.Request, resp *http.Response, body []byte) {
+	if !r.DebugHTTP {
+		return
+	}
+
+	r.logf("HTTP %v %v -> %v %v\n%v", req.Method, req.URL.String(), resp.StatusCode, resp.Header.Get("Content-Type"), string(body))
+}
+
+// Response body for errors, shortened unless -debug-http is used
+func (r *Reddit) bodyExcerpt(body []byte) string {
+	s := string(body)
+
+	if !r.DebugHTTP && len(s) > BODY_EXCERPT_LEN {
+		// Don't split multi-byte character
+		cut := BODY_EXCERPT_LEN
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		s = s[:cut] + `...`
+	}
+
+	return r.Redact(s)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestBodyExcerptRuneBoundary(t *testing.T) {
+	r := &Reddit{}
+
+	// Two-byte characters, odd cut would split one
+	body := `x` + strings.Repeat(`ä`, BODY_EXCERPT_LEN)
+
+	got := r.bodyExcerpt([]byte(body))
+	if !utf8.ValidString(got) {
+		t.Fatalf(`excerpt is not valid UTF-8: %q`, got)
+	}
+
+	if !strings.HasSuffix(got, `...`) || len(got) > BODY_EXCERPT_LEN+len(`...`) {
+		t.Errorf(`excerpt not shortened: %q`, got)
+	}
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 )
 
+const (
+	DEFAULT_AUTH_URL = `https://www.reddit.com`   // Default base URL for authorization and access tokens
+	DEFAULT_API_URL  = `https://oauth.reddit.com` // Default base URL for OAuth API calls
+
+	TOKEN_EXPIRY_MARGIN = time.Minute // Access token is renewed when it expires sooner than this
+)
+
 type RedditSubmitErrorJson struct {
 	JQuery [][]interface{} `json:"jquery,omitempty"`
 	JSON   struct {
@@ -25,19 +32,53 @@ type RedditSubmitErrorJson struct {
 	} `json:"json,omitempty"`
 }
 
+type RedditCommentJson struct {
+	JSON struct {
+		Errors [][]string `json:"errors,omitempty"`
+		Data   struct {
+			Things []struct {
+				Kind string `json:"kind"`
+				Data struct {
+					Id   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"data"`
+			} `json:"things,omitempty"`
+		} `json:"data,omitempty"`
+	} `json:"json,omitempty"`
+}
+
+type RedditListingJson struct {
+	Kind string `json:"kind"`
+	Data struct {
+		Children []struct {
+			Kind string `json:"kind"`
+			Data struct {
+				Name       string  `json:"name"`
+				Subreddit  string  `json:"subreddit"`
+				Title      string  `json:"title"`
+				Url        string  `json:"url"`
+				Permalink  string  `json:"permalink"`
+				CreatedUtc float64 `json:"created_utc"`
+			} `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
 type RedditAccessToken struct {
 	Id           string
 	Type         string
 	RefreshToken string
 	ExpiresIn    time.Time
+	Scope        string // Granted scopes separated by space, * for all
 }
 
 type RedditAccessTokenJson struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int64  `json:"expires_in"`
-	Scope       string `json:"scope"`
-	Error       string `json:"error,omitempty"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Error        string `json:"error,omitempty"`
 }
 
 type Reddit struct {
@@ -52,6 +93,10 @@ type Reddit struct {
 	Rate      time.Duration
 	State     string
 	Token     RedditAccessToken
+	AuthUrl   string // Base URL for authorization and access tokens
+	ApiUrl    string // Base URL for OAuth API calls
+	DebugHTTP bool   // Log full response bodies (credentials are redacted)
+	Retry     RetryPolicy
 	limiter   <-chan time.Time
 }
 
@@ -76,6 +121,8 @@ func New(username, password, id, secret string, userAgent string) Reddit {
 		Username:  username,
 		Password:  password,
 		UserAgent: userAgent,
+		AuthUrl:   DEFAULT_AUTH_URL,
+		ApiUrl:    DEFAULT_API_URL,
 		limiter:   limiter,
 		Token: RedditAccessToken{
 			Id:        "",
@@ -85,43 +132,86 @@ func New(username, password, id, secret string, userAgent string) Reddit {
 }
 
 // Log in to Reddit
-func (r *Reddit) Login() (err error) {
+// Uses refresh token if one is available, otherwise user name and password
+func (r *Reddit) Login(ctx context.Context) (err error) {
+	v := url.Values{}
+
+	if r.Token.RefreshToken != `` {
+		v.Set("grant_type", "refresh_token")
+		v.Set("refresh_token", r.Token.RefreshToken)
+	} else {
+		v.Set("grant_type", "password")
+		v.Set("username", r.Username)
+		v.Set("password", r.Password)
+	}
+
+	return r.requestToken(ctx, v)
+}
+
+// Is there no access token yet or is it about to expire
+func (r *Reddit) TokenExpired() bool {
+	return r.Token.Id == `` || !time.Now().Add(TOKEN_EXPIRY_MARGIN).Before(r.Token.ExpiresIn)
+}
+
+// URL where user authorizes the app in the authorization code flow
+// Reddit redirects back to r.Uri with the code and r.State
+func (r *Reddit) AuthorizeURL() string {
+	v := url.Values{}
+	v.Set("client_id", r.Id)
+	v.Set("response_type", "code")
+	v.Set("state", r.State)
+	v.Set("redirect_uri", r.Uri)
+	v.Set("duration", "permanent")
+	v.Set("scope", strings.Join(r.Scopes, " "))
+
+	return r.AuthUrl + "/api/v1/authorize?" + v.Encode()
+}
+
+// Exchange authorization code for access and refresh token
+func (r *Reddit) ExchangeCode(ctx context.Context, code string) (err error) {
 	v := url.Values{}
-	v.Set("grant_type", "password")
-	v.Set("username", r.Username)
-	v.Set("password", r.Password)
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", r.Uri)
+
+	err = r.requestToken(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	if r.Token.RefreshToken == `` {
+		return fmt.Errorf(`no refresh token received`)
+	}
+
+	return nil
+}
 
-	req, err := http.NewRequest("POST", "https://www.reddit.com/api/v1/access_token", strings.NewReader(v.Encode()))
+// Request access token from Reddit
+func (r *Reddit) requestToken(ctx context.Context, v url.Values) (err error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", r.AuthUrl+"/api/v1/access_token", strings.NewReader(v.Encode()))
 
 	if err != nil {
-		log.Println(err)
-		return fmt.Errorf(``)
+		return r.errorf(`error building request: %v`, err)
 	}
 	req.SetBasicAuth(r.Id, r.Secret)
 	req.Header.Add("User-Agent", r.UserAgent)
 
-	resp, err := r.Client.Do(req)
+	resp, htmlData, err := r.Retry.Do(r.Client, req, r.logf)
 
 	if err != nil {
-		log.Println(err)
-		return fmt.Errorf(`request error`)
+		return r.errorf(`request error: %v`, err)
 	}
 
+	r.debugHTTP(req, resp, htmlData)
+
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf(`status code %v`, resp.StatusCode)
 	}
 
-	htmlData, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println(err)
-		return fmt.Errorf(`read error`)
-	}
-	defer resp.Body.Close()
-
 	// Check content type
 	ctype := resp.Header.Get("Content-Type")
 	if !strings.Contains(ctype, `application/json`) {
-		return fmt.Errorf(`invalid content type: %v html: %v`, ctype, string(htmlData))
+		return r.errorf(`invalid content type: %v body: %v`, ctype, r.bodyExcerpt(htmlData))
 	}
 
 	// JSON to struct
@@ -136,107 +226,421 @@ func (r *Reddit) Login() (err error) {
 		return fmt.Errorf(`login error: %v`, tmp.Error)
 	}
 
+	// Refresh token is only returned on authorization, keep the old one
+	refreshToken := r.Token.RefreshToken
+	if tmp.RefreshToken != `` {
+		refreshToken = tmp.RefreshToken
+	}
+
 	// Generate token
 	r.Token = RedditAccessToken{
-		Id:        tmp.AccessToken,
-		ExpiresIn: time.Now().Add(time.Duration(tmp.ExpiresIn) * time.Second),
-		Type:      tmp.TokenType,
+		Id:           tmp.AccessToken,
+		ExpiresIn:    time.Now().Add(time.Duration(tmp.ExpiresIn) * time.Second),
+		Type:         tmp.TokenType,
+		Scope:        tmp.Scope,
+		RefreshToken: refreshToken,
 	}
 
 	return nil
 
 }
 
-func (r *Reddit) SubmitLink(link SubmitLink) error {
+// POST form to Reddit's OAuth API and return the JSON response body
+func (r *Reddit) apiPost(ctx context.Context, endpoint string, v url.Values) ([]byte, error) {
+	return r.apiRequest(ctx, "POST", endpoint, v)
+}
+
+// GET from Reddit's OAuth API with query parameters and return the JSON response body
+func (r *Reddit) apiGet(ctx context.Context, endpoint string, v url.Values) ([]byte, error) {
+	return r.apiRequest(ctx, "GET", endpoint, v)
+}
+
+func (r *Reddit) apiRequest(ctx context.Context, method string, endpoint string, v url.Values) ([]byte, error) {
+	var body io.Reader
+	uri := r.ApiUrl + endpoint
+
+	if method == "GET" {
+		uri += "?" + v.Encode()
+	} else {
+		body = strings.NewReader(v.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+
+	if err != nil {
+		return nil, r.errorf(`error building request: %v`, err)
+	}
+	req.Header.Add("User-Agent", r.UserAgent)
+	req.Header.Add("Authorization", fmt.Sprintf(`%v %v`, r.Token.Type, r.Token.Id))
+
+	// POST may have taken effect even if the response was lost, so only GET is retried
+	// Failed submits go back to the queue which checks Reddit before submitting again
+	retry := r.Retry
+	if method != "GET" {
+		retry = retry.once()
+	}
+
+	resp, htmlData, err := retry.Do(r.Client, req, r.logf)
+
+	if err != nil {
+		return nil, r.errorf(`request error: %v`, err)
+	}
+
+	r.debugHTTP(req, resp, htmlData)
+
+	// Check content type
+	ctype := resp.Header.Get("Content-Type")
+	if !strings.Contains(ctype, `application/json`) {
+		return nil, r.errorf(`invalid content type: %v status: %v body: %v`, ctype, resp.StatusCode, r.bodyExcerpt(htmlData))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ErrorAPI{
+			status: resp.StatusCode,
+			err:    r.bodyExcerpt(htmlData),
+			url:    req.URL.Path,
+		}
+	}
+
+	return htmlData, nil
+}
+
+// Collect error codes from API response
+func apiErrors(errors [][]string) []string {
+	var errs []string
+
+	if len(errors) > 0 {
+		for _, item := range errors[0] {
+			errs = append(errs, item)
+		}
+	}
+
+	return errs
+}
+
+func (r *Reddit) SubmitLink(ctx context.Context, link SubmitLink) (post SubmittedPost, err error) {
 	v := url.Values{}
 	v.Set("sr", link.SubReddit)
 	v.Set("title", link.Title)
-	v.Set("url", link.Url)
-	v.Set("kind", "link")
+
+	if link.Kind == KIND_SELF {
+		// Text post, URL is only used for cache
+		v.Set("kind", KIND_SELF)
+		v.Set("text", link.Text)
+	} else {
+		v.Set("kind", KIND_LINK)
+		v.Set("url", link.Url)
+	}
+
 	v.Set("uh", "")
-	//v.Set("flair_text", flair)
+
+	if link.FlairId != `` {
+		v.Set("flair_id", link.FlairId)
+
+		if link.Flair != `` {
+			v.Set("flair_text", link.Flair)
+		}
+	}
+
 	v.Set("resubmit", "false") // Do not resubmit existing link
 	//v.Set("ad", "false")
 	v.Set("nsfw", "false")
 	//v.Set("spoiler", "false")
 	v.Set("api_type", "json")
 
-	req, err := http.NewRequest("POST", "https://oauth.reddit.com/api/submit", strings.NewReader(v.Encode()))
+	return r.submit(ctx, v, link)
+}
 
+// Crosspost already submitted post to another subreddit
+// fullname is the name of the original post (t3_<id>)
+func (r *Reddit) SubmitCrosspost(ctx context.Context, link SubmitLink, subreddit string, fullname string) (post SubmittedPost, err error) {
+	v := url.Values{}
+	v.Set("sr", subreddit)
+	v.Set("title", link.Title)
+	v.Set("kind", KIND_CROSSPOST)
+	v.Set("crosspost_fullname", fullname)
+	v.Set("resubmit", "false") // Do not resubmit existing link
+	v.Set("nsfw", "false")
+	v.Set("api_type", "json")
+
+	return r.submit(ctx, v, link)
+}
+
+// Send submit form and parse response
+func (r *Reddit) submit(ctx context.Context, v url.Values, link SubmitLink) (post SubmittedPost, err error) {
+	htmlData, err := r.apiPost(ctx, `/api/submit`, v)
 	if err != nil {
-		log.Println(err)
-		return fmt.Errorf(`error building request`)
+		return post, err
 	}
-	req.Header.Add("User-Agent", r.UserAgent)
-	req.Header.Add("Authorization", fmt.Sprintf(`%v %v`, r.Token.Type, r.Token.Id))
 
-	resp, err := r.Client.Do(req)
+	// Convert JSON to struct
+	var tmp RedditSubmitErrorJson
+	err = json.Unmarshal(htmlData, &tmp)
+	if err != nil {
+		return post, r.errorf(`invalid response: %v body: %v`, err, r.bodyExcerpt(htmlData))
+	}
+
+	errs := apiErrors(tmp.JSON.Errors)
+
+	for _, item := range errs {
+		if item == `ALREADY_SUB` {
+			return post, &ErrorSubmitExists{
+				err:  `link already submitted`,
+				link: link,
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		post = SubmittedPost{
+			Id:   tmp.JSON.Data.Id,
+			Name: tmp.JSON.Data.Name,
+			Url:  tmp.JSON.Data.Url,
+		}
+
+		return post, nil
+	}
+
+	return post, &ErrorSubmit{
+		codes: errs,
+	}
+}
+
+// Find posts which link to given URL in any subreddit
+func (r *Reddit) FindSubmissions(ctx context.Context, linkUrl string) (posts []RedditPost, err error) {
+	v := url.Values{}
+	v.Set("url", linkUrl)
+	v.Set("limit", "100")
+	v.Set("raw_json", "1")
 
+	htmlData, err := r.apiGet(ctx, `/api/info`, v)
 	if err != nil {
-		log.Println(err)
-		return fmt.Errorf(`request error`)
+		return nil, err
 	}
 
-	htmlData, err := ioutil.ReadAll(resp.Body)
+	return r.parsePosts(htmlData)
+}
+
+// Latest posts submitted by given user
+func (r *Reddit) UserSubmissions(ctx context.Context, user string) (posts []RedditPost, err error) {
+	v := url.Values{}
+	v.Set("sort", "new")
+	v.Set("limit", "100")
+	v.Set("raw_json", "1")
+
+	htmlData, err := r.apiGet(ctx, fmt.Sprintf(`/user/%v/submitted`, url.PathEscape(user)), v)
 	if err != nil {
-		fmt.Println(err)
-		return fmt.Errorf(`read error`)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// Check content type
-	ctype := resp.Header.Get("Content-Type")
-	if !strings.Contains(ctype, `application/json`) {
-		return fmt.Errorf(`invalid content type: %v html: %v`, ctype, string(htmlData))
+	return r.parsePosts(htmlData)
+}
+
+// Posts from listing response
+func (r *Reddit) parsePosts(htmlData []byte) (posts []RedditPost, err error) {
+	var tmp RedditListingJson
+	err = json.Unmarshal(htmlData, &tmp)
+	if err != nil {
+		return nil, r.errorf(`invalid response: %v body: %v`, err, r.bodyExcerpt(htmlData))
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return &ErrorAPI{
-			err: string(htmlData),
-			url: req.URL.RequestURI(),
-			val: v.Encode(),
+	for _, child := range tmp.Data.Children {
+		if child.Kind != `t3` {
+			continue
 		}
+
+		posts = append(posts, RedditPost{
+			Name:      child.Data.Name,
+			SubReddit: child.Data.Subreddit,
+			Title:     child.Data.Title,
+			Url:       child.Data.Url,
+			Permalink: child.Data.Permalink,
+			Created:   time.Unix(int64(child.Data.CreatedUtc), 0),
+		})
 	}
 
-	log.Printf(`%v`, string(htmlData))
+	return posts, nil
+}
 
-	// Convert JSON to struct
-	var tmp RedditSubmitErrorJson
+// Comment on a post or reply to a comment
+// parent is the fullname of the thing (t3_xxx for posts)
+// Returns fullname of the new comment
+func (r *Reddit) Comment(ctx context.Context, parent string, text string) (name string, err error) {
+	v := url.Values{}
+	v.Set("thing_id", parent)
+	v.Set("text", text)
+	v.Set("api_type", "json")
+
+	htmlData, err := r.apiPost(ctx, `/api/comment`, v)
+	if err != nil {
+		return ``, err
+	}
+
+	var tmp RedditCommentJson
 	err = json.Unmarshal(htmlData, &tmp)
 	if err != nil {
-		return fmt.Errorf(`error: %v`, string(htmlData))
+		return ``, r.errorf(`invalid response: %v body: %v`, err, r.bodyExcerpt(htmlData))
 	}
 
-	var errs []string
+	errs := apiErrors(tmp.JSON.Errors)
+	if len(errs) > 0 {
+		return ``, &ErrorComment{
+			codes: errs,
+		}
+	}
 
-	if len(tmp.JSON.Errors) > 0 {
-		for _, item := range tmp.JSON.Errors[0] {
-			if item == `ALREADY_SUB` {
-				return &ErrorSubmitExists{
-					err:  `link already submitted`,
-					link: link,
-				}
-			}
+	if len(tmp.JSON.Data.Things) == 0 {
+		return ``, fmt.Errorf(`comment error: no comment returned`)
+	}
 
-			errs = append(errs, item)
-		}
+	return tmp.JSON.Data.Things[0].Data.Name, nil
+}
+
+// Distinguish a comment as moderator and optionally sticky it on top of the post
+// Requires that the bot account is a moderator of the subreddit
+func (r *Reddit) Distinguish(ctx context.Context, name string, sticky bool) (err error) {
+	v := url.Values{}
+	v.Set("id", name)
+	v.Set("how", "yes")
+	v.Set("sticky", fmt.Sprintf(`%v`, sticky))
+	v.Set("api_type", "json")
+
+	htmlData, err := r.apiPost(ctx, `/api/distinguish`, v)
+	if err != nil {
+		return err
 	}
 
-	if len(errs) == 0 {
-		return nil
+	var tmp RedditCommentJson
+	err = json.Unmarshal(htmlData, &tmp)
+	if err != nil {
+		return r.errorf(`invalid response: %v body: %v`, err, r.bodyExcerpt(htmlData))
+	}
+
+	errs := apiErrors(tmp.JSON.Errors)
+	if len(errs) > 0 {
+		return fmt.Errorf(`%v`, strings.Join(errs, ". "))
+	}
+
+	return nil
+}
+
+// Account of logged in user
+func (r *Reddit) Me(ctx context.Context) (account RedditAccount, err error) {
+	htmlData, err := r.apiGet(ctx, `/api/v1/me`, url.Values{})
+	if err != nil {
+		return account, err
 	}
 
-	return fmt.Errorf(`%v`, strings.Join(errs, ". "))
+	err = json.Unmarshal(htmlData, &account)
+	if err != nil {
+		return account, r.errorf(`invalid response: %v body: %v`, err, r.bodyExcerpt(htmlData))
+	}
+
+	return account, nil
+}
+
+// Descriptions of OAuth scopes by scope id, all scopes if none given
+func (r *Reddit) ScopeDescriptions(ctx context.Context, scopes []string) (descs map[string]RedditScope, err error) {
+	v := url.Values{}
+	if len(scopes) > 0 {
+		v.Set("scopes", strings.Join(scopes, ","))
+	}
+
+	htmlData, err := r.apiGet(ctx, `/api/v1/scopes`, v)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(htmlData, &descs)
+	if err != nil {
+		return nil, r.errorf(`invalid response: %v body: %v`, err, r.bodyExcerpt(htmlData))
+	}
+
+	return descs, nil
+}
+
+// Subreddit information with logged in user's relation to it
+// Returns ErrorAPI with status 404 if subreddit doesn't exist or is banned and 403 if it's private
+func (r *Reddit) SubredditAbout(ctx context.Context, name string) (sub RedditSubreddit, err error) {
+	v := url.Values{}
+	v.Set("raw_json", "1")
+
+	htmlData, err := r.apiGet(ctx, fmt.Sprintf(`/r/%v/about`, url.PathEscape(name)), v)
+	if err != nil {
+		return sub, err
+	}
+
+	var tmp struct {
+		Kind string          `json:"kind"`
+		Data RedditSubreddit `json:"data"`
+	}
+
+	err = json.Unmarshal(htmlData, &tmp)
+	if err != nil {
+		return sub, r.errorf(`invalid response: %v body: %v`, err, r.bodyExcerpt(htmlData))
+	}
+
+	if tmp.Kind != `t5` {
+		// Reddit answers with search results for unknown names
+		return sub, &ErrorAPI{
+			status: http.StatusNotFound,
+			err:    `subreddit not found`,
+			url:    `/r/` + name + `/about`,
+		}
+	}
+
+	return tmp.Data, nil
 }
 
 type ErrorAPI struct {
-	err string
-	url string
-	val string
+	status int
+	err    string
+	url    string
 }
 
 func (e *ErrorAPI) Error() string {
-	return fmt.Sprintf("submit error: %v URL: %v\n%v", e.err, e.url, e.val)
+	return fmt.Sprintf(`API error: status %v URL: %v %v`, e.status, e.url, e.err)
+}
+
+// Submit rejected by Reddit
+type ErrorSubmit struct {
+	codes []string // Error code, message and field from Reddit
+}
+
+func (e *ErrorSubmit) Error() string {
+	return fmt.Sprintf(`submit error: %v`, strings.Join(e.codes, ". "))
+}
+
+// Is submit rejected because of rate limit
+// Rate limit is not a problem of the link itself
+func (e *ErrorSubmit) RateLimited() bool {
+	for _, code := range e.codes {
+		if code == `RATELIMIT` {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Comment rejected by Reddit
+type ErrorComment struct {
+	codes []string // Error code, message and field from Reddit
+}
+
+func (e *ErrorComment) Error() string {
+	return fmt.Sprintf(`comment error: %v`, strings.Join(e.codes, ". "))
+}
+
+// Is comment rejected because of rate limit
+func (e *ErrorComment) RateLimited() bool {
+	for _, code := range e.codes {
+		if code == `RATELIMIT` {
+			return true
+		}
+	}
+
+	return false
 }
 
 type ErrorSubmitExists struct {
@@ -248,10 +652,68 @@ func (e *ErrorSubmitExists) Error() string {
 	return fmt.Sprintf(`submit error: %v URL: %v`, e.err, e.link.Url)
 }
 
+// Reddit user account
+type RedditAccount struct {
+	Name             string  `json:"name"`
+	LinkKarma        int     `json:"link_karma"`
+	CommentKarma     int     `json:"comment_karma"`
+	CreatedUtc       float64 `json:"created_utc"`
+	IsSuspended      bool    `json:"is_suspended"`
+	HasVerifiedEmail bool    `json:"has_verified_email"`
+}
+
+func (a RedditAccount) Created() time.Time {
+	return time.Unix(int64(a.CreatedUtc), 0)
+}
+
+// OAuth scope description
+type RedditScope struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Subreddit information
+type RedditSubreddit struct {
+	DisplayName       string `json:"display_name"`
+	SubredditType     string `json:"subreddit_type"`  // public, restricted, private, archived, ..
+	SubmissionType    string `json:"submission_type"` // any, link or self
+	Quarantine        bool   `json:"quarantine"`
+	UserIsBanned      bool   `json:"user_is_banned"`
+	UserIsContributor bool   `json:"user_is_contributor"` // Approved submitter
+	UserIsModerator   bool   `json:"user_is_moderator"`
+}
+
 // Submit link information
 type SubmitLink struct {
-	Title     string    // Title of post
-	Url       string    // URL of post
+	Title     string         `json:"title"`               // Title of post
+	Url       string         `json:"url"`                 // URL of post
+	SubReddit string         `json:"subreddit"`           // Subreddit name
+	Published time.Time      `json:"published"`           // Published date and time (used for cache)
+	Kind      string         `json:"kind"`                // Post kind: link or self
+	Text      string         `json:"text,omitempty"`      // Markdown body for text (self) posts
+	Comment   string         `json:"comment,omitempty"`   // Markdown body for first comment, no comment if empty
+	Sticky    bool           `json:"sticky,omitempty"`    // Distinguish and sticky the first comment (bot must be moderator)
+	Crosspost []string       `json:"crosspost,omitempty"` // Subreddits where the submitted post is crossposted to
+	FlairId   string         `json:"fid,omitempty"`       // Flair template ID of post, not used for crossposts
+	Flair     string         `json:"flair,omitempty"`     // Flair text of post, needs FlairId
+	DupCheck  time.Duration  `json:"dupcheck,omitempty"`  // Check Reddit for existing submissions of URL within this window, 0 disables
+	Windows   PostingWindows `json:"windows,omitempty"`   // Feed's posting windows
+}
+
+// Post found in Reddit
+type RedditPost struct {
+	Name      string    // Fullname of post (t3_<id>)
 	SubReddit string    // Subreddit name
-	Published time.Time // Published date and time (used for cache)
+	Title     string    // Title of post
+	Url       string    // Linked URL
+	Permalink string    // Path to post in Reddit
+	Created   time.Time // Submit time
+}
+
+// Successfully submitted post
+type SubmittedPost struct {
+	Id   string // Post ID
+	Name string // Fullname of post (t3_<id>)
+	Url  string // Post URL in Reddit
 }
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DEFAULT_RETRY_ATTEMPTS  = 3
+	DEFAULT_RETRY_DELAY     = time.Second * 2
+	DEFAULT_RETRY_MAX_DELAY = time.Minute
+	DEFAULT_RETRY_JITTER    = 0.2
+)
+
+// Retry policy for transient HTTP errors (network errors, 5xx and 429)
+type RetryPolicy struct {
+	Attempts int     `json:"attempts,omitempty"`  // Total attempts including the first one, 1 disables retrying
+	Delay    string  `json:"delay,omitempty"`     // Delay before first retry, doubled for each retry
+	MaxDelay string  `json:"max_delay,omitempty"` // Upper limit for delay
+	Jitter   float64 `json:"jitter,omitempty"`    // Random variation of delay, 0.2 = ±20%, negative disables
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.Attempts <= 0 {
+		return DEFAULT_RETRY_ATTEMPTS
+	}
+
+	return p.Attempts
+}
+
+func parseDurationDefault(s string, def time.Duration) time.Duration {
+	if s == `` {
+		return def
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		// Validated already
+		return def
+	}
+
+	return d
+}
+
+func (p *RetryPolicy) Validate() error {
+	if p.Attempts < 0 {
+		return fmt.Errorf(`negative retry attempts`)
+	}
+
+	for _, s := range []string{p.Delay, p.MaxDelay} {
+		if s == `` {
+			continue
+		}
+
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf(`invalid retry delay %v: %v`, s, err)
+		}
+
+		if d < 0 {
+			return fmt.Errorf(`negative retry delay %v`, s)
+		}
+	}
+
+	if p.Jitter > 1 {
+		return fmt.Errorf(`retry jitter must be at most 1, negative disables it`)
+	}
+
+	return nil
+}
+
+// Same policy without retrying, for requests which aren't safe to repeat
+func (p RetryPolicy) once() RetryPolicy {
+	p.Attempts = 1
+	return p
+}
+
+func (p RetryPolicy) maxDelay() time.Duration {
+	return parseDurationDefault(p.MaxDelay, DEFAULT_RETRY_MAX_DELAY)
+}
+
+// Delay before retry number n (1 = first retry)
+// Retry-After from server is used if it's longer, Do doesn't retry if it's longer than max delay
+func (p RetryPolicy) Backoff(n int, retryAfter time.Duration) time.Duration {
+	delay := parseDurationDefault(p.Delay, DEFAULT_RETRY_DELAY)
+	maxDelay := p.maxDelay()
+
+	for i := 1; i < n && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	jitter := p.Jitter
+	switch {
+	case jitter == 0:
+		jitter = DEFAULT_RETRY_JITTER
+	case jitter < 0:
+		jitter = 0
+	}
+
+	delay = time.Duration(float64(delay) * (1 + jitter*(rand.Float64()*2-1)))
+
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	return delay
+}
+
+// Parse Retry-After header, either seconds or HTTP date
+func retryAfter(resp *http.Response) time.Duration {
+	h := resp.Header.Get(`Retry-After`)
+	if h == `` {
+		return 0
+	}
+
+	secs, err := strconv.Atoi(h)
+	if err == nil {
+		return time.Duration(secs) * time.Second
+	}
+
+	t, err := http.ParseTime(h)
+	if err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
+
+// Is response status worth retrying
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// Send request and read response body, retrying on network errors, 5xx and 429
+// Response of the last attempt is returned as is when retries run out or Retry-After is longer than max delay
+func (p RetryPolicy) Do(client *http.Client, req *http.Request, logf func(format string, args ...interface{})) (resp *http.Response, body []byte, err error) {
+	attempts := p.attempts()
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			// Body was consumed by previous attempt
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		resp, err = client.Do(req)
+		if err == nil {
+			body, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		var wait time.Duration
+
+		if err != nil {
+			if attempt >= attempts {
+				return nil, nil, err
+			}
+
+			wait = p.Backoff(attempt, 0)
+			logf(`%v %v failed: %v, retrying in %v (attempt %v/%v)`, req.Method, req.URL.Path, err, wait.Round(time.Millisecond), attempt+1, attempts)
+		} else if retryableStatus(resp.StatusCode) && attempt < attempts {
+			after := retryAfter(resp)
+			if after > p.maxDelay() {
+				// Retrying sooner than server allows would fail again
+				logf(`%v %v returned status %v with Retry-After %v, longer than max delay, not retrying`, req.Method, req.URL.Path, resp.StatusCode, after.Round(time.Second))
+				return resp, body, nil
+			}
+
+			wait = p.Backoff(attempt, after)
+			logf(`%v %v returned status %v, retrying in %v (attempt %v/%v)`, req.Method, req.URL.Path, resp.StatusCode, wait.Round(time.Millisecond), attempt+1, attempts)
+		} else {
+			return resp, body, nil
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, nil, req.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Directory for cache files and other state kept between runs
+var stateDir = STATE_DIR
+
+// Path of file in state directory
+func StatePath(name string) string {
+	return filepath.Join(stateDir, name)
+}
+
+// Path of subreddit's submitted cache file
+func cacheFile(subReddit string) string {
+	return StatePath(fmt.Sprintf(`%s.cache`, subReddit))
+}
+
+// Write file via temporary file and rename so that readers never see a partial file
+func writeFileAtomic(fname string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(fname), filepath.Base(fname))
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), fname)
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Pause between submits so that API isn't overloaded and bot doesn't get banned
+const SUBMIT_PAUSE = time.Second * 2
+
+// Drains the submission queue
+type Submitter struct {
+	Reddit          *Reddit
+	Queue           *Queue
+	Feeds           *FeedConfig   // Per subreddit posting limits
+	QuarantineAfter int           // Failed attempts before item is marked failed
+	Pause           time.Duration // Pause between submits, see SUBMIT_PAUSE
+	Failed          int           // Failed submits in this run
+	Stopped         bool          // Last run was stopped early because of rate limit, unauthorized or Reddit error
+
+	errlog *log.Logger
+}
+
+// Add new links to queue, links already in cache or queue are ignored
+// Returns number of links added
+func enqueueLinks(queue *Queue, links []SubmitLink) (added int, err error) {
+	caches := make(map[string]map[string]time.Time)
+
+	for _, link := range links {
+		submitted, ok := caches[link.SubReddit]
+		if !ok {
+			log.Printf(`Loading submitted cache..`)
+			submitted, err = LoadSubmitted(cacheFile(link.SubReddit))
+			if err != nil {
+				return added, err
+			}
+
+			caches[link.SubReddit] = submitted
+		}
+
+		// Check local cache
+		_, ok = submitted[link.Url]
+
+		if ok && !OVERRIDE_SUBMITTED_CHECK {
+			continue
+		}
+
+		if queue.Add(link) != nil {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// Save queue after item changed, errors are only logged as the submit itself succeeded or failed already
+func (s *Submitter) save() {
+	err := s.Queue.Save()
+	if err != nil {
+		s.errlog.Printf(`error: saving queue: %v`, err)
+	}
+}
+
+// Add item to submitted cache, errors are only logged as queue also keeps the status
+func (s *Submitter) remember(item *QueueItem) {
+	err := rememberSubmitted(item.Link.SubReddit, item.Link)
+	if err != nil {
+		s.errlog.Printf(`error: saving submitted cache: %v`, err)
+	}
+}
+
+// Record successful submit
+func (s *Submitter) done(item *QueueItem, post string) {
+	s.remember(item)
+	item.Submitted(post)
+	s.save()
+}
+
+// Record skipped link
+func (s *Submitter) skip(item *QueueItem, reason string) {
+	s.remember(item)
+	item.Skipped(reason)
+	s.save()
+}
+
+// Record failed submit, returns true if submitting should be stopped for this run
+// Only links rejected by Reddit count as failed attempts, other errors aren't a problem of the link itself
+func (s *Submitter) fail(ctx context.Context, item *QueueItem, err error) (stop bool) {
+	if ctx.Err() != nil {
+		s.errlog.Printf(`Submitting %v to %v aborted, keeping it in queue: %v`, item.Link.Url, item.Link.SubReddit, err)
+		s.interrupt(item, err)
+		return true
+	}
+
+	e, ok := err.(*ErrorSubmit)
+	if !ok {
+		if e, ok := err.(*ErrorAPI); ok && e.status == http.StatusUnauthorized {
+			s.errlog.Printf(`Unauthorized, stopping for this run: %v`, err)
+
+			// Log in again on next run, refresh token is kept
+			s.Reddit.Token.Id = ``
+
+			return true
+		}
+
+		s.errlog.Printf(`error: submitting %v to %v, stopping for this run: %v`, item.Link.Url, item.Link.SubReddit, err)
+		s.interrupt(item, err)
+		return true
+	}
+
+	if e.RateLimited() {
+		s.errlog.Printf(`Rate limited, stopping for this run: %v`, err)
+		return true
+	}
+
+	s.Failed++
+
+	item.Failed(err, s.QuarantineAfter)
+
+	if item.Status == STATUS_FAILED {
+		s.errlog.Printf(`error: submitting %v to %v failed %v times, giving up: %v`, item.Link.Url, item.Link.SubReddit, item.Attempts, err)
+	} else {
+		s.errlog.Printf(`error: submitting %v to %v failed (attempt %v/%v), retrying after %v: %v`, item.Link.Url, item.Link.SubReddit, item.Attempts, s.QuarantineAfter, item.NextAttempt.Format(time.RFC3339), err)
+	}
+
+	s.save()
+
+	return false
+}
+
+// Record submit which may or may not have reached Reddit, so that Reddit is checked before submitting again
+func (s *Submitter) interrupt(item *QueueItem, err error) {
+	item.Interrupted(err)
+	s.save()
+}
+
+// Check posting limits and windows, item is postponed if they don't allow posting now
+// Returns true if item can be submitted now
+func (s *Submitter) allowed(item *QueueItem) bool {
+	now := time.Now()
+
+	limits := s.Feeds.SubredditSettings(item.Link.SubReddit)
+	next := limits.NextAllowed(s.Queue.SubmitHistory(item.Link.SubReddit), now)
+
+	reason := `Posting limit reached`
+	if !next.After(now) {
+		reason = `Outside posting window`
+	}
+
+	next = nextOpenAll(next, limits.Windows, item.Link.Windows)
+
+	if !next.After(now) {
+		return true
+	}
+
+	log.Printf(`%v for %v, postponing %v until %v`, reason, item.Link.SubReddit, item.Link.Url, next.Format(time.RFC3339))
+	item.NextAttempt = next
+	s.save()
+
+	return false
+}
+
+// Submit given queue items in order until ctx is cancelled
+func (s *Submitter) Run(ctx context.Context, items []*QueueItem) {
+	s.Stopped = false
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !s.allowed(item) {
+			continue
+		}
+
+		if s.submit(ctx, item) {
+			s.Stopped = ctx.Err() == nil
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.Pause):
+		}
+	}
+}
+
+// Submit queue items which are due now and post comments refused earlier, logs in first if needed
+func (s *Submitter) SubmitDue(ctx context.Context) (err error) {
+	due := s.Queue.Due(time.Now())
+	comments := s.Queue.CommentsDue()
+
+	if len(due) == 0 && len(comments) == 0 {
+		return nil
+	}
+
+	if s.Reddit.TokenExpired() {
+		log.Printf(`Logging in..`)
+
+		err = s.Reddit.Login(ctx)
+		if err != nil {
+			return withExitCode(EXIT_AUTH, fmt.Errorf(`login failed: %w`, err))
+		}
+	}
+
+	for _, item := range comments {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		s.comment(ctx, item)
+	}
+
+	s.Run(ctx, due)
+
+	return nil
+}
+
+// Submit single queue item, returns true if submitting should be stopped for this run
+// Requests in progress get SHUTDOWN_GRACE to finish after ctx is cancelled
+func (s *Submitter) submit(ctx context.Context, item *QueueItem) (stop bool) {
+	link := item.Link
+
+	reqCtx, cancel := withGrace(ctx, SHUTDOWN_GRACE)
+	defer cancel()
+
+	if item.CrosspostOf != `` {
+		log.Printf(`Crossposting %v to %v: %v`, item.CrosspostOf, link.SubReddit, link.Title)
+
+		post, err := s.Reddit.SubmitCrosspost(reqCtx, link, link.SubReddit, item.CrosspostOf)
+		if err != nil {
+			if _, ok := err.(*ErrorSubmitExists); ok {
+				s.errlog.Printf("Already crossposted: %v", link.Url)
+				s.skip(item, `already crossposted`)
+				return false
+			}
+
+			return s.fail(reqCtx, item, err)
+		}
+
+		s.done(item, post.Name)
+		return false
+	}
+
+	log.Printf(`Submitting to %v: %v [%v] - %v`, link.SubReddit, link.Title, link.Published, link.Url)
+
+	post, lost, err := s.findLostSubmit(reqCtx, item)
+	if err != nil {
+		return s.fail(reqCtx, item, err)
+	}
+
+	if !lost {
+		if s.duplicate(reqCtx, item) {
+			return false
+		}
+
+		post, err = s.Reddit.SubmitLink(reqCtx, link)
+		if err != nil {
+			serr, ok := err.(*ErrorSubmitExists)
+
+			if ok {
+				s.errlog.Printf("Already submitted: %v - %#v", link.Url, serr)
+				s.skip(item, `already submitted`)
+				return false
+			}
+
+			return s.fail(reqCtx, item, err)
+		}
+	}
+
+	s.done(item, post.Name)
+
+	if link.Comment != `` {
+		s.comment(reqCtx, item)
+	}
+
+	// Queue crossposts of the original post
+	for _, target := range link.Crosspost {
+		crosspost := link
+		crosspost.SubReddit = target
+		crosspost.Kind = KIND_CROSSPOST
+		crosspost.Crosspost = nil
+		crosspost.Comment = ``
+
+		// Unreadable cache doesn't prevent crossposting, Reddit rejects duplicates anyway
+		submitted, err := LoadSubmitted(cacheFile(target))
+		if err != nil {
+			s.errlog.Printf(`error: %v`, err)
+		}
+
+		if _, ok := submitted[link.Url]; ok && !OVERRIDE_SUBMITTED_CHECK {
+			continue
+		}
+
+		if cp := s.Queue.Add(crosspost); cp != nil {
+			cp.CrosspostOf = post.Name
+		}
+	}
+
+	if len(link.Crosspost) > 0 {
+		s.save()
+
+		// Crosspost right away, items stay in queue if this run is stopped
+		for _, cp := range s.Queue.Due(time.Now()) {
+			if cp.CrosspostOf != post.Name {
+				continue
+			}
+
+			if !s.allowed(cp) {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return true
+			case <-time.After(s.Pause):
+			}
+
+			if s.submit(ctx, cp) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Check if failed attempt created the post even though its response was lost
+// Links are looked up by URL and text posts by title from the account's own submissions
+func (s *Submitter) findLostSubmit(ctx context.Context, item *QueueItem) (post SubmittedPost, found bool, err error) {
+	if item.Reason == `` {
+		return post, false, nil
+	}
+
+	// Reddit's creation times have second precision and its clock may differ, so allow a margin
+	since := item.Added.Add(-time.Minute)
+
+	var existing RedditPost
+
+	switch item.Link.Kind {
+	case KIND_LINK:
+		existing, found, err = findExistingSubmission(ctx, s.Reddit, item.Link, since)
+	case KIND_SELF:
+		existing, found, err = findOwnSubmission(ctx, s.Reddit, item.Link, since)
+	}
+
+	if err != nil || !found {
+		return post, false, err
+	}
+
+	log.Printf(`Previous attempt was submitted to %v as %v: %v`, existing.SubReddit, existing.Name, item.Link.Url)
+
+	return SubmittedPost{Name: existing.Name, Url: existing.Url}, true, nil
+}
+
+// Check Reddit for link submitted within duplicate check window, item is skipped if one is found
+// Returns true if item was skipped
+func (s *Submitter) duplicate(ctx context.Context, item *QueueItem) bool {
+	link := item.Link
+
+	if link.DupCheck <= 0 || link.Kind != KIND_LINK {
+		return false
+	}
+
+	existing, found, err := findExistingSubmission(ctx, s.Reddit, link, time.Now().Add(-link.DupCheck))
+	if err != nil {
+		s.errlog.Printf(`error: checking existing submissions of %v - %v`, link.Url, err)
+		return false
+	}
+
+	if !found {
+		return false
+	}
+
+	log.Printf(`Already submitted to %v at %v: %v - skipping %v`, existing.SubReddit, existing.Created, existing.Permalink, link.Url)
+	s.skip(item, fmt.Sprintf(`already submitted as %v`, existing.Name))
+
+	return true
+}
+
+// Post first comment on submitted item
+// Comments refused because of rate limit or authorization are posted again on next run, other failures may have posted the comment already
+func (s *Submitter) comment(ctx context.Context, item *QueueItem) {
+	err := postFirstComment(ctx, s.Reddit, item.Post, item.Link, s.errlog)
+
+	item.CommentDue = false
+
+	switch e := err.(type) {
+	case *ErrorComment:
+		item.CommentDue = e.RateLimited()
+	case *ErrorAPI:
+		item.CommentDue = e.status == http.StatusUnauthorized || e.status == http.StatusTooManyRequests
+	}
+
+	if item.CommentDue {
+		log.Printf(`Commenting on %v again on next run`, item.Post)
+	}
+
+	s.save()
+}
+
+// Add link to subreddit's submitted cache
+func rememberSubmitted(subReddit string, link SubmitLink) error {
+	submitFile := cacheFile(subReddit)
+
+	submitted, err := LoadSubmitted(submitFile)
+	if err != nil {
+		return err
+	}
+
+	submitted[link.Url] = link.Published
+
+	log.Printf(`Saving submitted cache..`)
+	return SaveSubmitted(submitFile, submitted)
+}
+
+// Check Reddit if link was already submitted to its subreddit after since
+func findExistingSubmission(ctx context.Context, redditClient *Reddit, link SubmitLink, since time.Time) (post RedditPost, found bool, err error) {
+	posts, err := redditClient.FindSubmissions(ctx, link.Url)
+	if err != nil {
+		return post, false, err
+	}
+
+	for _, p := range posts {
+		if !strings.EqualFold(p.SubReddit, link.SubReddit) {
+			continue
+		}
+
+		if p.Created.Before(since) {
+			continue
+		}
+
+		return p, true, nil
+	}
+
+	return post, false, nil
+}
+
+// Post the first comment on a freshly submitted post
+// Failures are logged and returned, failing to distinguish the comment is only logged
+func postFirstComment(ctx context.Context, redditClient *Reddit, post string, link SubmitLink, errlog *log.Logger) error {
+	log.Printf(`Commenting on %v..`, post)
+
+	name, err := redditClient.Comment(ctx, post, link.Comment)
+	if err != nil {
+		errlog.Printf(`error: commenting on %v (%v) - %v`, post, link.Url, err)
+		return err
+	}
+
+	if !link.Sticky {
+		return nil
+	}
+
+	err = redditClient.Distinguish(ctx, name, true)
+	if err != nil {
+		errlog.Printf(`error: distinguishing comment %v on %v - %v`, name, post, err)
+	}
+
+	return nil
+}
+
+// Check logged in account's submissions for post with link's title in its subreddit submitted after since
+func findOwnSubmission(ctx context.Context, redditClient *Reddit, link SubmitLink, since time.Time) (post RedditPost, found bool, err error) {
+	user := redditClient.Username
+	if user == `` {
+		// Logged in with refresh token
+		account, err := redditClient.Me(ctx)
+		if err != nil {
+			return post, false, err
+		}
+
+		user = account.Name
+	}
+
+	posts, err := redditClient.UserSubmissions(ctx, user)
+	if err != nil {
+		return post, false, err
+	}
+
+	for _, p := range posts {
+		if strings.EqualFold(p.SubReddit, link.SubReddit) && p.Title == link.Title && !p.Created.Before(since) {
+			return p, true, nil
+		}
+	}
+
+	return post, false, nil
+}
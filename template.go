@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"github.com/mmcdole/gofeed"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// Default body for text posts
+	DEFAULT_SELF_TEMPLATE = "{{.Summary}}\n\n[Read more]({{.Url}})"
+	// Default max length of summary in text post body or comment
+	DEFAULT_SUMMARY_MAXLEN = 2000
+	// Reddit's limit for text post body
+	REDDIT_SELF_MAXLEN = 40000
+	// Reddit's limit for comment body
+	REDDIT_COMMENT_MAXLEN = 10000
+)
+
+// Data available in text post body and comment templates
+type ItemTemplateData struct {
+	Title       string    // Sanitized title of item
+	Url         string    // URL of item
+	Feed        string    // Feed title from feed configuration
+	Author      string    // Author of item if feed has one
+	Published   time.Time // Published date and time
+	Description string    // Item description as markdown
+	Content     string    // Item content as markdown
+	Summary     string    // Content or description as markdown, shortened to max length
+}
+
+// Collect template data from feed item
+func NewItemTemplateData(feedSource FeedSource, item *gofeed.Item, sl SubmitLink) ItemTemplateData {
+	maxLen := feedSource.MaxLength
+	if maxLen == 0 {
+		maxLen = DEFAULT_SUMMARY_MAXLEN
+	}
+
+	data := ItemTemplateData{
+		Title:       sl.Title,
+		Url:         sl.Url,
+		Feed:        feedSource.Title,
+		Published:   sl.Published,
+		Description: HTMLToMarkdown(item.Description),
+		Content:     HTMLToMarkdown(item.Content),
+	}
+
+	if item.Author != nil {
+		data.Author = item.Author.Name
+	}
+
+	data.Summary = data.Content
+	if data.Summary == `` {
+		data.Summary = data.Description
+	}
+
+	data.Summary = TruncateMarkdown(data.Summary, maxLen)
+
+	return data
+}
+
+// Parse item template
+func ParseItemTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Parse(text)
+}
+
+// Render item template and limit the result to maxLen characters
+func RenderItemTemplate(tpl *template.Template, data ItemTemplateData, maxLen int) (string, error) {
+	var buf bytes.Buffer
+	err := tpl.Execute(&buf, data)
+	if err != nil {
+		return ``, err
+	}
+
+	return TruncateMarkdown(strings.TrimSpace(buf.String()), maxLen), nil
+}
+
+// Parse text post body template, empty template uses DEFAULT_SELF_TEMPLATE
+func NewSelfTextTemplate(text string) (*template.Template, error) {
+	if text == `` {
+		text = DEFAULT_SELF_TEMPLATE
+	}
+
+	return ParseItemTemplate(`self`, text)
+}
+
+// Build markdown body for a text post from feed item
+func BuildSelfText(feedSource FeedSource, data ItemTemplateData) (string, error) {
+	tpl, err := NewSelfTextTemplate(feedSource.Template)
+	if err != nil {
+		return ``, err
+	}
+
+	return RenderItemTemplate(tpl, data, REDDIT_SELF_MAXLEN)
+}
+
+// Build markdown body for the first comment from feed item
+func BuildComment(feedSource FeedSource, data ItemTemplateData) (string, error) {
+	tpl, err := ParseItemTemplate(`comment`, feedSource.Comment)
+	if err != nil {
+		return ``, err
+	}
+
+	return RenderItemTemplate(tpl, data, REDDIT_COMMENT_MAXLEN)
+}
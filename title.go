@@ -0,0 +1,134 @@
+package main
+
+import (
+	"golang.org/x/net/html/atom"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Tags which separate words in titles
+var titleSeparatorTags = map[atom.Atom]bool{
+	atom.Br: true, atom.Hr: true, atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Table: true, atom.Tr: true, atom.Td: true, atom.Th: true, atom.Figure: true,
+}
+
+// Add feed's prefix and suffix to sanitized title, separated by a space
+func DecorateTitle(prefix string, title string, suffix string) string {
+	parts := []string{}
+
+	for _, s := range []string{prefix, title, suffix} {
+		s = strings.TrimSpace(s)
+		if s != `` {
+			parts = append(parts, s)
+		}
+	}
+
+	return strings.Join(parts, ` `)
+}
+
+// Clean up feed item title before it's submitted to Reddit
+// HTML tags are removed, entities decoded, control characters dropped and whitespace collapsed
+// Only known tags, comments and CDATA sections are markup, any other < is text such as in "a<b" or "Vec<T>"
+func SanitizeTitle(title string) string {
+	var sb strings.Builder
+
+	rest := title
+
+	for rest != `` {
+		i := strings.IndexByte(rest, '<')
+		if i < 0 {
+			sb.WriteString(rest)
+			break
+		}
+
+		sb.WriteString(rest[:i])
+		rest = rest[i:]
+
+		n, text, sep := titleMarkup(rest)
+		if n == 0 {
+			sb.WriteByte('<')
+			rest = rest[1:]
+			continue
+		}
+
+		// Block tags and <br> separate words, inline tags such as <b>F</b>oo don't
+		if sep {
+			sb.WriteString(` `)
+		}
+
+		sb.WriteString(text)
+		rest = rest[n:]
+	}
+
+	// Some feeds double-encode entities (&amp;#8217;)
+	cleaned := html.UnescapeString(html.UnescapeString(sb.String()))
+
+	// Remove control characters and turn all whitespace into plain spaces
+	cleaned = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+
+		if unicode.Is(unicode.Cf, r) {
+			// Zero width spaces, BOMs, etc
+			return -1
+		}
+
+		return r
+	}, cleaned)
+
+	// Collapse whitespace
+	return strings.Join(strings.Fields(cleaned), ` `)
+}
+
+// Markup at the start of s: known HTML tag, comment or CDATA section
+// Returns its length (0 if s doesn't start with markup), text inside CDATA and whether it separates words
+func titleMarkup(s string) (n int, text string, sep bool) {
+	switch {
+	case strings.HasPrefix(s, `<![CDATA[`):
+		end := strings.Index(s, `]]>`)
+		if end < 0 {
+			return 0, ``, false
+		}
+
+		return end + len(`]]>`), s[len(`<![CDATA[`):end], false
+	case strings.HasPrefix(s, `<!--`):
+		end := strings.Index(s, `-->`)
+		if end < 0 {
+			return 0, ``, false
+		}
+
+		return end + len(`-->`), ``, false
+	}
+
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		return 0, ``, false
+	}
+
+	name := strings.TrimPrefix(s[1:end], `/`)
+	attrs := ``
+
+	if i := strings.IndexAny(name, " \t\n/"); i >= 0 {
+		name, attrs = name[:i], strings.TrimSpace(name[i:])
+	}
+
+	// "a<b and c>d" isn't a tag, attributes must have values
+	if attrs != `` && attrs != `/` && !strings.Contains(attrs, `=`) {
+		return 0, ``, false
+	}
+
+	a := atom.Lookup([]byte(strings.ToLower(name)))
+	if a == 0 {
+		return 0, ``, false
+	}
+
+	return end + 1, ``, titleSeparatorTags[a]
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestSanitizeTitle(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`<b>F</b>oo`, `Foo`},
+		{`Foo<br>Bar`, `Foo Bar`},
+		{`<p>Foo</p><p>Bar</p>`, `Foo Bar`},
+		{`Foo &amp;amp; <i>Bar</i>`, `Foo & Bar`},
+		{"Foo\tBar\u200b\n", `Foo Bar`},
+		{`Why a<b matters`, `Why a<b matters`},
+		{`Vec<T> and Option<T> explained`, `Vec<T> and Option<T> explained`},
+		{`If a<b and c>d`, `If a<b and c>d`},
+		{`<![CDATA[hi]]>`, `hi`},
+		{`Foo<!-- comment -->Bar`, `FooBar`},
+		{`<a href="https://example.com">Foo</a> <BR/>Bar`, `Foo Bar`},
+	}
+
+	for _, tt := range tests {
+		got := SanitizeTitle(tt.in)
+		if got != tt.want {
+			t.Errorf(`SanitizeTitle(%q) = %q, want %q`, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDecorateTitle(t *testing.T) {
+	tests := []struct {
+		prefix string
+		title  string
+		suffix string
+		want   string
+	}{
+		{``, `Foo`, ``, `Foo`},
+		{`[News]`, `Foo`, ``, `[News] Foo`},
+		{`[News] `, `Foo`, ` (via Bar)`, `[News] Foo (via Bar)`},
+		{` `, `Foo`, `|`, `Foo |`},
+	}
+
+	for _, tt := range tests {
+		got := DecorateTitle(tt.prefix, tt.title, tt.suffix)
+		if got != tt.want {
+			t.Errorf(`DecorateTitle(%q, %q, %q) = %q, want %q`, tt.prefix, tt.title, tt.suffix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Time of day when posting is allowed
+type PostingWindow struct {
+	Days     []string `json:"days,omitempty"` // Weekdays (mon, tue, ..), empty is every day
+	From     string   `json:"from"`           // Start time HH:MM
+	To       string   `json:"to"`             // End time HH:MM, window continues to next day if it's before From
+	TimeZone string   `json:"tz,omitempty"`   // IANA time zone, for example Europe/Helsinki, default is UTC
+}
+
+// Posting is allowed during any of the windows, no windows allows posting any time
+type PostingWindows []PostingWindow
+
+var weekdays = map[string]time.Weekday{
+	`sun`: time.Sunday,
+	`mon`: time.Monday,
+	`tue`: time.Tuesday,
+	`wed`: time.Wednesday,
+	`thu`: time.Thursday,
+	`fri`: time.Friday,
+	`sat`: time.Saturday,
+}
+
+// Parse HH:MM to minutes since midnight
+func parseClock(s string) (int, error) {
+	var h, m int
+
+	_, err := fmt.Sscanf(s, `%d:%d`, &h, &m)
+	if err != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf(`invalid time %q, use HH:MM`, s)
+	}
+
+	return h*60 + m, nil
+}
+
+func (w PostingWindow) location() (*time.Location, error) {
+	if w.TimeZone == `` {
+		return time.UTC, nil
+	}
+
+	return time.LoadLocation(w.TimeZone)
+}
+
+func (w PostingWindow) Validate() error {
+	_, err := parseClock(w.From)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseClock(w.To)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.location()
+	if err != nil {
+		return fmt.Errorf(`invalid time zone %v: %v`, w.TimeZone, err)
+	}
+
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf(`invalid weekday %q, use mon, tue, wed, thu, fri, sat or sun`, day)
+		}
+	}
+
+	return nil
+}
+
+func (w PostingWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Start and end of the window on given day, end is on next day if window continues past midnight
+func (w PostingWindow) bounds(day time.Time) (start, end time.Time) {
+	from, _ := parseClock(w.From)
+	to, _ := parseClock(w.To)
+
+	if from == to {
+		// Whole day
+		to = from + 24*60
+	}
+
+	start = time.Date(day.Year(), day.Month(), day.Day(), from/60, from%60, 0, 0, day.Location())
+
+	end = time.Date(day.Year(), day.Month(), day.Day(), to/60, to%60, 0, 0, day.Location())
+	if to <= from {
+		end = time.Date(day.Year(), day.Month(), day.Day()+1, to/60, to%60, 0, 0, day.Location())
+	}
+
+	return start, end
+}
+
+// Earliest time at or after t inside the window
+func (w PostingWindow) NextOpen(t time.Time) time.Time {
+	loc, err := w.location()
+	if err != nil {
+		// Validated already
+		return t
+	}
+
+	local := t.In(loc)
+
+	// Start from previous day as a window may continue past midnight
+	for d := -1; d <= 7; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, loc)
+
+		if !w.onDay(day.Weekday()) {
+			continue
+		}
+
+		start, end := w.bounds(day)
+
+		if !t.Before(start) && t.Before(end) {
+			return t
+		}
+
+		if start.After(t) {
+			return start
+		}
+	}
+
+	// No allowed days
+	return t
+}
+
+func (ws PostingWindows) Validate() error {
+	for _, w := range ws {
+		err := w.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Earliest time at or after t inside any window
+func (ws PostingWindows) NextOpen(t time.Time) time.Time {
+	if len(ws) == 0 {
+		return t
+	}
+
+	var next time.Time
+
+	for i, w := range ws {
+		open := w.NextOpen(t)
+		if i == 0 || open.Before(next) {
+			next = open
+		}
+	}
+
+	return next
+}
+
+// Earliest time at or after t allowed by all window lists
+func nextOpenAll(t time.Time, lists ...PostingWindows) time.Time {
+	// Each round can only move forward, a week of rounds covers all combinations
+	for i := 0; i < 7*24*2; i++ {
+		moved := false
+
+		for _, ws := range lists {
+			open := ws.NextOpen(t)
+			if open.After(t) {
+				t = open
+				moved = true
+			}
+		}
+
+		if !moved {
+			break
+		}
+	}
+
+	return t
+}
+
+// Reference weeks for windowsOverlap, in winter and summer as daylight saving time may move windows
+var referenceWeeks = []time.Time{
+	time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC),
+	time.Date(2001, time.July, 2, 0, 0, 0, 0, time.UTC),
+}
+
+// Open periods of the windows during days around the week starting at ref
+// A few extra days on both sides cover windows past midnight and time zone offsets
+func (ws PostingWindows) periods(ref time.Time) (periods [][2]time.Time) {
+	for _, w := range ws {
+		loc, err := w.location()
+		if err != nil {
+			// Validated already
+			continue
+		}
+
+		for d := -2; d < 7+2; d++ {
+			day := time.Date(ref.Year(), ref.Month(), ref.Day()+d, 0, 0, 0, 0, loc)
+
+			if !w.onDay(day.Weekday()) {
+				continue
+			}
+
+			start, end := w.bounds(day)
+			periods = append(periods, [2]time.Time{start, end})
+		}
+	}
+
+	return periods
+}
+
+// Check if all window lists allow posting at some time
+// Windows repeat weekly, so checking fixed reference weeks gives the same result whenever config is validated
+func windowsOverlap(lists ...PostingWindows) bool {
+	for _, ref := range referenceWeeks {
+		var all [][][2]time.Time
+
+		for _, ws := range lists {
+			if len(ws) > 0 {
+				all = append(all, ws.periods(ref))
+			}
+		}
+
+		if len(all) == 0 {
+			return true
+		}
+
+		// If periods overlap, the latest start of the overlapping periods is inside all of them
+		for _, periods := range all {
+			for _, p := range periods {
+				if insideAll(p[0], all) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Check if t is inside some period of every list
+func insideAll(t time.Time, all [][][2]time.Time) bool {
+	for _, periods := range all {
+		inside := false
+
+		for _, p := range periods {
+			if !t.Before(p[0]) && t.Before(p[1]) {
+				inside = true
+				break
+			}
+		}
+
+		if !inside {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestWindowsOverlap(t *testing.T) {
+	tests := []struct {
+		name    string
+		feed    PostingWindows
+		sub     PostingWindows
+		overlap bool
+	}{
+		{`no subreddit windows`, PostingWindows{{From: `12:00`, To: `14:00`}}, nil, true},
+		{`overlapping hours`, PostingWindows{{From: `12:00`, To: `14:00`}}, PostingWindows{{From: `13:00`, To: `18:00`}}, true},
+		{`disjoint hours`, PostingWindows{{From: `12:00`, To: `14:00`}}, PostingWindows{{From: `15:00`, To: `18:00`}}, false},
+		{`past midnight`, PostingWindows{{From: `22:00`, To: `02:00`}}, PostingWindows{{From: `01:00`, To: `03:00`}}, true},
+		{`disjoint days`, PostingWindows{{Days: []string{`sat`, `sun`}, From: `10:00`, To: `18:00`}}, PostingWindows{{Days: []string{`mon`, `fri`}, From: `08:00`, To: `22:00`}}, false},
+		{`time zones`, PostingWindows{{From: `12:00`, To: `14:00`, TimeZone: `Asia/Tokyo`}}, PostingWindows{{From: `03:00`, To: `04:00`}}, true},
+		{`whole day`, PostingWindows{{From: `00:00`, To: `00:00`}}, PostingWindows{{Days: []string{`wed`}, From: `08:00`, To: `09:00`}}, true},
+		{`days in time zones`, PostingWindows{{Days: []string{`sat`}, From: `23:00`, To: `23:30`, TimeZone: `Pacific/Auckland`}}, PostingWindows{{Days: []string{`sat`}, From: `10:00`, To: `10:30`}}, true},
+		{`any of windows`, PostingWindows{{From: `12:00`, To: `14:00`}}, PostingWindows{{From: `08:00`, To: `09:00`}, {From: `13:30`, To: `15:00`}}, true},
+	}
+
+	for _, test := range tests {
+		if got := windowsOverlap(test.feed, test.sub); got != test.overlap {
+			t.Errorf(`%v: windowsOverlap() = %v, want %v`, test.name, got, test.overlap)
+		}
+	}
+}